@@ -0,0 +1,84 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.public {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.public)
+		}
+	}
+}
+
+func TestCheckCallbackURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		internal bool
+		invalid  bool
+	}{
+		{url: "https://93.184.215.14/hook"},
+		{url: "http://169.254.169.254/latest/meta-data/", internal: true},
+		{url: "http://localhost:6379/", internal: true},
+		{url: "http://api.localhost/", internal: true},
+		{url: "http://[::1]:8080/", internal: true},
+		{url: "http://10.0.0.5/hook", internal: true},
+		{url: "ftp://93.184.215.14/hook", invalid: true},
+		{url: "/relative/hook", invalid: true},
+	}
+	for _, tt := range tests {
+		err := CheckCallbackURL(context.Background(), tt.url)
+		switch {
+		case tt.internal && !errors.Is(err, ErrInternalDestination):
+			t.Errorf("CheckCallbackURL(%s) = %v, want ErrInternalDestination", tt.url, err)
+		case tt.invalid && err == nil:
+			t.Errorf("CheckCallbackURL(%s) accepted an invalid URL", tt.url)
+		case !tt.internal && !tt.invalid && err != nil:
+			t.Errorf("CheckCallbackURL(%s) = %v, want nil", tt.url, err)
+		}
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// The test server listens on loopback, as an internal service would
+	_, err := newWebhookClient().Post(server.URL, "application/json", strings.NewReader("{}"))
+	if !errors.Is(err, ErrInternalDestination) {
+		t.Fatalf("Post to %s: got %v, want ErrInternalDestination", server.URL, err)
+	}
+	if called {
+		t.Error("the internal server received the webhook")
+	}
+}
@@ -0,0 +1,150 @@
+// Package alerts re-checks watched SKUs on a schedule and notifies each
+// watch's callback URL through signed webhooks when its condition is met.
+package alerts
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ABCScraper/scrapers"
+	"ABCScraper/storage"
+)
+
+// Evaluator periodically checks every watch against the live price
+type Evaluator struct {
+	repo       storage.Repository
+	client     *http.Client
+	interval   time.Duration
+	deliveries chan delivery
+
+	// lookup fetches the live product for a SKU; tests swap it out
+	lookup func(ctx context.Context, sku string, priority scrapers.Priority) (scrapers.ProductResult, scrapers.ProductVariant, error)
+}
+
+// delivery is one alert queued for the webhook workers
+type delivery struct {
+	watch   storage.Watch
+	payload AlertPayload
+}
+
+// NewEvaluator creates an Evaluator that checks watches every interval
+func NewEvaluator(repo storage.Repository, interval time.Duration) *Evaluator {
+	return &Evaluator{
+		repo:       repo,
+		client:     newWebhookClient(),
+		interval:   interval,
+		deliveries: make(chan delivery, DeliveryQueue),
+		lookup:     scrapers.LookupVariant,
+	}
+}
+
+// Run evaluates the watchlist every interval until ctx is done, delivering
+// webhooks on DeliveryWorkers background workers
+func (e *Evaluator) Run(ctx context.Context) {
+	for i := 0; i < DeliveryWorkers; i++ {
+		go e.deliverLoop(ctx)
+	}
+
+	for {
+		e.Evaluate(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// Evaluate checks every watch once. Each watched SKU is looked up once no
+// matter how many watches share it, and alerts are queued for the webhook workers.
+func (e *Evaluator) Evaluate(ctx context.Context) {
+	watches, err := e.repo.Watches()
+	if err != nil {
+		log.Printf("Failed to load watchlist: %v", err)
+		return
+	}
+
+	bySKU := make(map[string][]storage.Watch)
+	for _, watch := range watches {
+		bySKU[watch.SKU] = append(bySKU[watch.SKU], watch)
+	}
+
+	for sku, skuWatches := range bySKU {
+		product, variant, err := e.lookup(ctx, sku, scrapers.PriorityBackground)
+		if err != nil {
+			log.Printf("Failed to check watched sku %s: %v", sku, err)
+			continue
+		}
+
+		for _, watch := range skuWatches {
+			event := matchEvent(watch, product, variant)
+
+			// Alert when the condition starts holding, and re-arm once it stops
+			if (event != "") == watch.Triggered {
+				continue
+			}
+
+			// Only the trigger state is written back, so an edit made while
+			// the lookup was in flight is not overwritten
+			updated, err := e.repo.MarkWatchTriggered(watch.ID, event != "", time.Now())
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Failed to update watch %d: %v", watch.ID, err)
+				continue
+			}
+
+			if event != "" {
+				e.enqueue(ctx, *updated, AlertPayload{
+					Event:     event,
+					WatchID:   watch.ID,
+					SKU:       sku,
+					Title:     product.Title,
+					Size:      variant.Size,
+					Price:     variant.Price,
+					Threshold: watch.Threshold,
+					OnSale:    product.OnSale,
+					Timestamp: time.Now(),
+				})
+			}
+		}
+	}
+}
+
+// Helper function to queue an alert for delivery, giving up if ctx ends first
+func (e *Evaluator) enqueue(ctx context.Context, watch storage.Watch, payload AlertPayload) {
+	select {
+	case e.deliveries <- delivery{watch: watch, payload: payload}:
+	case <-ctx.Done():
+		log.Printf("Dropped alert for watch %d: %v", watch.ID, ctx.Err())
+	}
+}
+
+// deliverLoop sends queued alerts until ctx is done
+func (e *Evaluator) deliverLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-e.deliveries:
+			e.deliver(ctx, d.watch, d.payload)
+		}
+	}
+}
+
+// matchEvent returns the alert event a watch should fire for the current
+// price, or "" when its condition does not hold
+func matchEvent(watch storage.Watch, product scrapers.ProductResult, variant scrapers.ProductVariant) string {
+	if watch.Threshold > 0 && variant.Price > 0 && variant.Price < watch.Threshold {
+		return EventPriceBelow
+	}
+	if watch.OnSale && product.OnSale {
+		return EventOnSale
+	}
+	return ""
+}
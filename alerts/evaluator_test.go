@@ -0,0 +1,110 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+
+	"ABCScraper/scrapers"
+	"ABCScraper/storage"
+)
+
+// Helper function to stub the live lookup with a fixed price and sale flag
+func stubLookup(e *Evaluator, price *float64, onSale *bool) {
+	e.lookup = func(ctx context.Context, sku string, priority scrapers.Priority) (scrapers.ProductResult, scrapers.ProductVariant, error) {
+		return scrapers.ProductResult{Title: "Test Bourbon", OnSale: *onSale},
+			scrapers.ProductVariant{SKU: sku, Size: "750ml", Price: *price}, nil
+	}
+}
+
+// Helper function to take every alert Evaluate queued
+func drain(e *Evaluator) []delivery {
+	var queued []delivery
+	for {
+		select {
+		case d := <-e.deliveries:
+			queued = append(queued, d)
+		default:
+			return queued
+		}
+	}
+}
+
+func TestEvaluateTriggersAndRearms(t *testing.T) {
+	e := newTestEvaluator(t)
+	price, onSale := 30.0, false
+	stubLookup(e, &price, &onSale)
+
+	watch := &storage.Watch{SKU: "012345", Threshold: 25, CallbackURL: "https://example.com/hook"}
+	if err := e.repo.SaveWatch(watch); err != nil {
+		t.Fatalf("SaveWatch: %v", err)
+	}
+	ctx := context.Background()
+
+	e.Evaluate(ctx)
+	if queued := drain(e); len(queued) != 0 {
+		t.Fatalf("alerted above the threshold: %+v", queued)
+	}
+
+	// Dropping below the threshold alerts once
+	price = 22.99
+	e.Evaluate(ctx)
+	queued := drain(e)
+	if len(queued) != 1 || queued[0].payload.Event != EventPriceBelow || queued[0].payload.Price != 22.99 {
+		t.Fatalf("queued = %+v, want one price_below alert", queued)
+	}
+	stored, _ := e.repo.Watch(watch.ID)
+	if !stored.Triggered || stored.LastAlertAt.IsZero() {
+		t.Errorf("watch after alert = %+v, want triggered", stored)
+	}
+
+	e.Evaluate(ctx)
+	if queued := drain(e); len(queued) != 0 {
+		t.Fatalf("alerted again while still below the threshold: %+v", queued)
+	}
+
+	// Going back above re-arms the watch without alerting
+	price = 30
+	e.Evaluate(ctx)
+	if queued := drain(e); len(queued) != 0 {
+		t.Fatalf("alerted on re-arm: %+v", queued)
+	}
+	if stored, _ := e.repo.Watch(watch.ID); stored.Triggered {
+		t.Error("watch still triggered after the price recovered")
+	}
+
+	price = 20
+	e.Evaluate(ctx)
+	if queued := drain(e); len(queued) != 1 {
+		t.Fatalf("queued %d alerts after re-arming, want 1", len(queued))
+	}
+}
+
+func TestEvaluateKeepsConcurrentEdits(t *testing.T) {
+	e := newTestEvaluator(t)
+
+	watch := &storage.Watch{SKU: "012345", OnSale: true, CallbackURL: "https://example.com/old"}
+	if err := e.repo.SaveWatch(watch); err != nil {
+		t.Fatalf("SaveWatch: %v", err)
+	}
+
+	// The watch is edited while its SKU is being looked up
+	e.lookup = func(ctx context.Context, sku string, priority scrapers.Priority) (scrapers.ProductResult, scrapers.ProductVariant, error) {
+		edited := *watch
+		edited.CallbackURL = "https://example.com/new"
+		if err := e.repo.SaveWatch(&edited); err != nil {
+			t.Fatalf("SaveWatch: %v", err)
+		}
+		return scrapers.ProductResult{OnSale: true}, scrapers.ProductVariant{SKU: sku}, nil
+	}
+
+	e.Evaluate(context.Background())
+
+	stored, _ := e.repo.Watch(watch.ID)
+	if stored.CallbackURL != "https://example.com/new" || !stored.Triggered {
+		t.Errorf("stored watch = %+v, want the edited URL and triggered", stored)
+	}
+	queued := drain(e)
+	if len(queued) != 1 || queued[0].watch.CallbackURL != "https://example.com/new" {
+		t.Fatalf("queued = %+v, want one alert to the edited URL", queued)
+	}
+}
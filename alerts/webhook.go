@@ -0,0 +1,140 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ABCScraper/storage"
+)
+
+// Alert events sent to watch callbacks
+const (
+	EventPriceBelow = "price_below"
+	EventOnSale     = "on_sale"
+)
+
+// AlertPayload is the JSON body POSTed to a watch's callback URL
+type AlertPayload struct {
+	Event     string    `json:"event"`
+	WatchID   uint64    `json:"watch_id"`
+	SKU       string    `json:"sku"`
+	Title     string    `json:"title"`
+	Size      string    `json:"size"`
+	Price     float64   `json:"price"`
+	Threshold float64   `json:"threshold,omitempty"`
+	OnSale    bool      `json:"on_sale"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Webhook delivery settings
+var (
+	// DeliveryAttempts is how many times a webhook is tried before it is dead-lettered
+	DeliveryAttempts = 5
+
+	// InitialBackoff is the wait before the first retry, doubling after each failure
+	InitialBackoff = 2 * time.Second
+
+	// MaxBackoff caps the wait between retries
+	MaxBackoff = time.Minute
+
+	// DeliveryWorkers is how many webhooks are delivered concurrently
+	DeliveryWorkers = 4
+
+	// DeliveryQueue is how many alerts can wait for a free worker
+	DeliveryQueue = 64
+)
+
+// Sign returns the hex HMAC-SHA256 of a timestamp and body, which receivers
+// recompute with the watch secret to check X-Webhook-Signature
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver POSTs a signed alert to the watch's callback URL, retrying with
+// exponential backoff and dead-lettering it when every attempt fails or ctx
+// ends while it waits to retry
+func (e *Evaluator) deliver(ctx context.Context, watch storage.Watch, payload AlertPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode alert for watch %d: %v", watch.ID, err)
+		return
+	}
+
+	backoff := InitialBackoff
+	attempts := DeliveryAttempts
+	var lastErr error
+	for attempt := 1; attempt <= DeliveryAttempts; attempt++ {
+		if lastErr = e.post(ctx, watch, body); lastErr == nil {
+			return
+		}
+
+		log.Printf("Webhook for watch %d failed (attempt %d/%d): %v", watch.ID, attempt, DeliveryAttempts, lastErr)
+
+		// Retrying cannot make an internal address public
+		if errors.Is(lastErr, ErrInternalDestination) {
+			attempts = attempt
+			break
+		}
+		if attempt < DeliveryAttempts {
+			select {
+			case <-ctx.Done():
+				attempts, lastErr = attempt, ctx.Err()
+			case <-time.After(backoff):
+				backoff = min(backoff*2, MaxBackoff)
+				continue
+			}
+			break
+		}
+	}
+
+	letter := &storage.DeadLetter{
+		WatchID:     watch.ID,
+		CallbackURL: watch.CallbackURL,
+		Payload:     body,
+		Attempts:    attempts,
+		LastError:   lastErr.Error(),
+		FailedAt:    time.Now(),
+	}
+	if err := e.repo.AddDeadLetter(letter); err != nil {
+		log.Printf("Failed to dead-letter webhook for watch %d: %v", watch.ID, err)
+	}
+}
+
+// post makes one signed delivery attempt
+func (e *Evaluator) post(ctx context.Context, watch storage.Watch, body []byte) error {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, watch.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ABCScraper-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(watch.Secret, timestamp, body))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ABCScraper/storage"
+)
+
+// newTestEvaluator returns an Evaluator over a fresh bolt repository whose
+// webhook client may reach the loopback test servers
+func newTestEvaluator(t *testing.T) *Evaluator {
+	t.Helper()
+
+	repo, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	e := NewEvaluator(repo, time.Hour)
+	e.client = http.DefaultClient
+	return e
+}
+
+// Helper function to shorten the retry backoff for one test
+func fastBackoff(t *testing.T, initial time.Duration) {
+	t.Helper()
+	oldInitial, oldMax := InitialBackoff, MaxBackoff
+	InitialBackoff, MaxBackoff = initial, initial
+	t.Cleanup(func() { InitialBackoff, MaxBackoff = oldInitial, oldMax })
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"on_sale"}`)
+
+	got := Sign("secret", 1700000000, body)
+	if got != Sign("secret", 1700000000, body) {
+		t.Fatal("Sign is not deterministic")
+	}
+	if len(got) != 64 {
+		t.Errorf("Sign returned %d hex chars, want 64", len(got))
+	}
+	if got == Sign("other", 1700000000, body) {
+		t.Error("signature did not change with the secret")
+	}
+	if got == Sign("secret", 1700000001, body) {
+		t.Error("signature did not change with the timestamp")
+	}
+	if got == Sign("secret", 1700000000, []byte(`{"event":"price_below"}`)) {
+		t.Error("signature did not change with the body")
+	}
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	e := newTestEvaluator(t)
+
+	var verified atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("bad timestamp header: %v", err)
+		}
+
+		// Verify the way a receiver would
+		want := "sha256=" + Sign("s3cret", timestamp, body)
+		if r.Header.Get("X-Webhook-Signature") != want {
+			t.Errorf("X-Webhook-Signature = %q, want %q", r.Header.Get("X-Webhook-Signature"), want)
+		}
+
+		var payload AlertPayload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.SKU != "012345" {
+			t.Errorf("payload = %+v (%v), want sku 012345", payload, err)
+		}
+		verified.Store(true)
+	}))
+	defer server.Close()
+
+	watch := storage.Watch{ID: 1, CallbackURL: server.URL, Secret: "s3cret"}
+	e.deliver(context.Background(), watch, AlertPayload{Event: EventOnSale, WatchID: 1, SKU: "012345"})
+
+	if !verified.Load() {
+		t.Fatal("callback was not called")
+	}
+	letters, _ := e.repo.DeadLetters(10)
+	if len(letters) != 0 {
+		t.Errorf("got %d dead letters after a successful delivery", len(letters))
+	}
+}
+
+func TestDeliverRetriesThenDeadLetters(t *testing.T) {
+	fastBackoff(t, time.Millisecond)
+	e := newTestEvaluator(t)
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	watch := storage.Watch{ID: 7, CallbackURL: server.URL}
+	e.deliver(context.Background(), watch, AlertPayload{Event: EventPriceBelow, WatchID: 7})
+
+	if got := int(calls.Load()); got != DeliveryAttempts {
+		t.Errorf("callback called %d times, want %d", got, DeliveryAttempts)
+	}
+	letters, err := e.repo.DeadLetters(10)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("DeadLetters = %v, %v; want one letter", letters, err)
+	}
+	letter := letters[0]
+	if letter.WatchID != 7 || letter.Attempts != DeliveryAttempts || letter.CallbackURL != server.URL {
+		t.Errorf("dead letter = %+v", letter)
+	}
+	if !strings.Contains(letter.LastError, "502") {
+		t.Errorf("LastError = %q, want the callback status", letter.LastError)
+	}
+}
+
+func TestDeliverStopsOnShutdown(t *testing.T) {
+	fastBackoff(t, time.Hour)
+	e := newTestEvaluator(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.deliver(ctx, storage.Watch{ID: 3, CallbackURL: server.URL}, AlertPayload{WatchID: 3})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deliver kept waiting to retry after ctx was cancelled")
+	}
+
+	letters, _ := e.repo.DeadLetters(10)
+	if len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Fatalf("dead letters = %+v, want one after a single attempt", letters)
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"ABCScraper/cache"
+)
+
+// Helper function to report whether a response came from the cache and how old it is
+func setCacheHeaders[T any](w http.ResponseWriter, result cache.Result[T]) {
+	switch {
+	case result.Stale:
+		w.Header().Set("X-Cache", "STALE")
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	case result.Hit:
+		w.Header().Set("X-Cache", "HIT")
+	default:
+		w.Header().Set("X-Cache", "MISS")
+	}
+	w.Header().Set("Age", strconv.Itoa(int(result.Age.Seconds())))
+}
+
+// Helper function to flag a response built from a stale cached result
+func markStale[T any](response *APIResponse, result cache.Result[T]) {
+	if !result.Stale {
+		return
+	}
+	response.Stale = true
+	response.AgeSeconds = int(result.Age.Seconds())
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ABCScraper/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// Handler for listing every stored product
+func (s *Server) catalogProductsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	products, err := s.repository.Products()
+	if err != nil {
+		sendErrorResponse(w, "Failed to read stored products: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if products == nil {
+		products = []storage.ProductRecord{}
+	}
+
+	sendSuccessResponse(w, products)
+}
+
+// Handler for reading one stored product by its key
+func (s *Server) catalogProductHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	product, err := s.repository.Product(mux.Vars(r)["key"])
+	if errors.Is(err, storage.ErrNotFound) {
+		sendErrorResponse(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to read stored product: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, product)
+}
+
+// Handler for reading one stored variant by SKU
+func (s *Server) catalogVariantHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	sku := mux.Vars(r)["sku"]
+	if !isValidSKU(sku) {
+		sendErrorResponse(w, "Invalid SKU format. Must be 6 digits.", http.StatusBadRequest)
+		return
+	}
+
+	variant, err := s.repository.Variant(sku)
+	if errors.Is(err, storage.ErrNotFound) {
+		sendErrorResponse(w, "SKU not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to read stored variant: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendSuccessResponse(w, variant)
+}
+
+// Handler for listing recent scrape runs
+func (s *Server) scrapeRunsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 1000 {
+			sendErrorResponse(w, "Invalid limit. Must be 1-1000.", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	runs, err := s.repository.Runs(limit)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read scrape runs: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if runs == nil {
+		runs = []storage.RunRecord{}
+	}
+
+	sendSuccessResponse(w, runs)
+}
+
+// Helper function to reject requests when the server runs without storage
+func (s *Server) requireRepository(w http.ResponseWriter) bool {
+	if s.repository == nil {
+		sendErrorResponse(w, "Catalog storage is not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// Helper function to send a successful response with data
+func sendSuccessResponse(w http.ResponseWriter, data interface{}) {
+	response := APIResponse{
+		Status:    "success",
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
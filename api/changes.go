@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"ABCScraper/storage"
+)
+
+// changesWindow is how far back the change log goes when no ?since= is given
+const changesWindow = 7 * 24 * time.Hour
+
+// Handler for the catalog change log, so clients can delta-sync from ?since=
+// (RFC 3339) instead of downloading the whole catalog again
+func (s *Server) changesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	since, ok := parseSince(w, r, changesWindow)
+	if !ok {
+		return
+	}
+
+	changes, err := s.repository.Changes(since)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read catalog changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if changes == nil {
+		changes = []storage.Change{}
+	}
+
+	sendSuccessResponse(w, changes)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"ABCScraper/scrapers"
+)
+
+// scrapeErrors maps the scrapers' error kinds to the status and code clients
+// see. The kind's own description is shown instead of the wrapped error, which
+// can carry upstream details.
+var scrapeErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{scrapers.ErrTokenExpired, http.StatusUnauthorized, "token_expired"},
+	{scrapers.ErrGeocodeNotFound, http.StatusNotFound, "geocode_not_found"},
+	{scrapers.ErrProductNotFound, http.StatusNotFound, "product_not_found"},
+	{scrapers.ErrCloudflareChallenge, http.StatusServiceUnavailable, "cloudflare_challenge"},
+	{scrapers.ErrUpstreamBlocked, http.StatusServiceUnavailable, "upstream_blocked"},
+	{scrapers.ErrUpstreamTimeout, http.StatusGatewayTimeout, "upstream_timeout"},
+	{scrapers.ErrUpstreamSchema, http.StatusBadGateway, "upstream_schema"},
+	{scrapers.ErrUpstreamFailed, http.StatusBadGateway, "upstream_failed"},
+}
+
+// Helper function to send a scraper failure with the status and code for its
+// kind. The full error is only logged.
+func (s *Server) sendScrapeError(w http.ResponseWriter, message string, err error) {
+	s.logger.Printf("%s: %v", message, err)
+
+	// Asking the client to come back later when our limiter turned the
+	// request away or a circuit is open
+	var limited *scrapers.RateLimitedError
+	if errors.As(err, &limited) {
+		setRetryAfter(w, limited.RetryAfter)
+		sendErrorCode(w, message+": "+limited.Error(), http.StatusServiceUnavailable, "rate_limited")
+		return
+	}
+
+	var unavailable *scrapers.UpstreamUnavailableError
+	if errors.As(err, &unavailable) {
+		setRetryAfter(w, unavailable.RetryAfter)
+		sendErrorCode(w, message+": "+unavailable.Error(), http.StatusServiceUnavailable, "upstream_unavailable")
+		return
+	}
+
+	// The client went away, so there is no one to answer
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	for _, kind := range scrapeErrors {
+		if errors.Is(err, kind.err) {
+			sendErrorCode(w, message+": "+kind.err.Error(), kind.status, kind.code)
+			return
+		}
+	}
+
+	// The endpoint's deadline passed while waiting, e.g. for the upstream limiter
+	if errors.Is(err, context.DeadlineExceeded) {
+		sendErrorCode(w, message+": request deadline exceeded", http.StatusGatewayTimeout, "deadline_exceeded")
+		return
+	}
+
+	sendErrorCode(w, message, http.StatusInternalServerError, "internal_error")
+}
+
+// Helper function to set Retry-After in whole seconds
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// Helper function to derive an error code such as "not_found" from a status code
+func statusErrorCode(statusCode int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(statusCode)), " ", "_")
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ABCScraper/cache"
+	"ABCScraper/scrapers"
+)
+
+// Export formats accepted by the stores endpoints through ?format=
+const (
+	formatJSON    = "json"
+	formatGeoJSON = "geojson"
+	formatKML     = "kml"
+)
+
+// GeoJSON types for exporting store locations as a FeatureCollection
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// KML types for exporting store locations as Placemarks
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"kml"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Document struct {
+		Name       string         `xml:"name"`
+		Placemarks []kmlPlacemark `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+type kmlPlacemark struct {
+	Name         string    `xml:"name"`
+	Address      string    `xml:"address,omitempty"`
+	Description  string    `xml:"description,omitempty"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Point        kmlPoint  `xml:"Point"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// Helper function to validate the ?format= query parameter
+func isValidStoreFormat(format string) bool {
+	switch format {
+	case "", formatJSON, formatGeoJSON, formatKML:
+		return true
+	}
+	return false
+}
+
+// writeStores sends stores in the format requested through ?format=,
+// defaulting to the standard JSON API response
+func writeStores(w http.ResponseWriter, r *http.Request, name string, stores []scrapers.StoreResult, result cache.Result[[]scrapers.StoreResult]) {
+	switch r.URL.Query().Get("format") {
+	case formatGeoJSON:
+		writeStoresGeoJSON(w, stores)
+	case formatKML:
+		writeStoresKML(w, name, stores)
+	default:
+		response := APIResponse{
+			Status:    "success",
+			Data:      stores,
+			Timestamp: time.Now(),
+		}
+		markStale(&response, result)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// writeStoresGeoJSON sends stores as a GeoJSON FeatureCollection of points
+func writeStoresGeoJSON(w http.ResponseWriter, stores []scrapers.StoreResult) {
+	collection := geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJSONFeature{},
+	}
+
+	for _, store := range stores {
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type: "Feature",
+			Geometry: geoJSONPoint{
+				Type: "Point",
+				// GeoJSON positions are longitude first
+				Coordinates: [2]float64{store.Longitude, store.Latitude},
+			},
+			Properties: map[string]interface{}{
+				"title":    store.Title,
+				"address":  store.Address,
+				"zip_code": store.ZipCode,
+				"hours":    store.Hours,
+				"distance": store.Distance,
+				"source":   store.Source,
+			},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(collection)
+}
+
+// writeStoresKML sends stores as a KML document with one Placemark per store
+func writeStoresKML(w http.ResponseWriter, name string, stores []scrapers.StoreResult) {
+	doc := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2"}
+	doc.Document.Name = name
+
+	for _, store := range stores {
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:        store.Title,
+			Address:     store.Address,
+			Description: store.Hours,
+			ExtendedData: []kmlData{
+				{Name: "zip_code", Value: store.ZipCode},
+				{Name: "hours", Value: store.Hours},
+				{Name: "distance", Value: fmt.Sprintf("%d", store.Distance)},
+				{Name: "source", Value: store.Source},
+			},
+			// KML coordinates are longitude,latitude
+			Point: kmlPoint{Coordinates: fmt.Sprintf("%f,%f", store.Longitude, store.Latitude)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(doc)
+}
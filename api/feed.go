@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"ABCScraper/storage"
+)
+
+// releaseFeedWindow is how far back the release feed goes when no ?since= is given
+const releaseFeedWindow = 30 * 24 * time.Hour
+
+// Feed formats accepted by the release feed through ?format=
+const (
+	formatAtom = "atom"
+	formatRSS  = "rss"
+)
+
+// ReleaseItem is a product whose new, limited or lottery flag turned on
+type ReleaseItem struct {
+	ProductKey string    `json:"product_key"`
+	Title      string    `json:"title"`
+	Flag       string    `json:"flag"`
+	URL        string    `json:"url"`
+	Image      string    `json:"image"`
+	Prices     string    `json:"prices"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Atom feed types
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID       string   `xml:"id"`
+	Title    string   `xml:"title"`
+	Updated  string   `xml:"updated"`
+	Link     atomLink `xml:"link"`
+	Category struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Summary string `xml:"summary"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+}
+
+// RSS 2.0 feed types
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Items       []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	Category    string `xml:"category"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+}
+
+// Handler for the feed of products whose new, limited or lottery flag turned
+// on since ?since= (RFC 3339), as JSON, Atom or RSS
+func (s *Server) releaseFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != formatJSON && format != formatAtom && format != formatRSS {
+		sendErrorResponse(w, "Invalid format. Must be json, atom or rss.", http.StatusBadRequest)
+		return
+	}
+
+	since, ok := parseSince(w, r, releaseFeedWindow)
+	if !ok {
+		return
+	}
+
+	changes, err := s.repository.Changes(since)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read catalog changes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	items := []ReleaseItem{}
+	for _, change := range changes {
+		for _, flag := range releaseFlags(change) {
+			items = append(items, ReleaseItem{
+				ProductKey: change.ProductKey,
+				Title:      change.Product.Title,
+				Flag:       flag,
+				URL:        change.Product.URL,
+				Image:      change.Product.Image,
+				Prices:     change.Product.SizesPrice,
+				DetectedAt: change.DetectedAt,
+			})
+		}
+	}
+
+	// Newest first, as feed readers expect
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+
+	switch format {
+	case formatAtom:
+		writeReleasesAtom(w, items)
+	case formatRSS:
+		writeReleasesRSS(w, items)
+	default:
+		sendSuccessResponse(w, items)
+	}
+}
+
+// writeReleasesAtom sends release items as an Atom feed
+func writeReleasesAtom(w http.ResponseWriter, items []ReleaseItem) {
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		ID:      "urn:abcscraper:releases",
+		Title:   "Virginia ABC new and limited releases",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	for _, item := range items {
+		entry := atomEntry{
+			ID:      fmt.Sprintf("urn:abcscraper:release:%s:%s:%d", item.ProductKey, item.Flag, item.DetectedAt.Unix()),
+			Title:   item.Title,
+			Updated: item.DetectedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: item.URL},
+			Summary: releaseSummary(item),
+		}
+		entry.Category.Term = item.Flag
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// writeReleasesRSS sends release items as an RSS 2.0 feed
+func writeReleasesRSS(w http.ResponseWriter, items []ReleaseItem) {
+	feed := rssFeed{Version: "2.0"}
+	feed.Channel.Title = "Virginia ABC new and limited releases"
+	feed.Channel.Link = "https://www.abc.virginia.gov/products"
+	feed.Channel.Description = "Products whose new, limited availability or lottery flag turned on"
+
+	for _, item := range items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        fmt.Sprintf("%s:%s:%d", item.ProductKey, item.Flag, item.DetectedAt.Unix()),
+			Category:    item.Flag,
+			Description: releaseSummary(item),
+			PubDate:     item.DetectedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(feed)
+}
+
+// releaseFlags returns the new, limited and lottery flags a change turned on.
+// A product added to the catalog with any of them set counts as turning them on.
+func releaseFlags(change storage.Change) []string {
+	if change.Product == nil {
+		return nil
+	}
+
+	switch change.Kind {
+	case storage.ChangeFlagOn:
+		switch change.Field {
+		case storage.FlagNew, storage.FlagLimited, storage.FlagLottery:
+			return []string{change.Field}
+		}
+	case storage.ChangeAdded:
+		var flags []string
+		if change.Product.NewProduct {
+			flags = append(flags, storage.FlagNew)
+		}
+		if change.Product.LimitedAvailability {
+			flags = append(flags, storage.FlagLimited)
+		}
+		if change.Product.Lottery {
+			flags = append(flags, storage.FlagLottery)
+		}
+		return flags
+	}
+	return nil
+}
+
+// releaseSummary describes a release item in one line for feed readers
+func releaseSummary(item ReleaseItem) string {
+	switch item.Flag {
+	case storage.FlagNew:
+		return fmt.Sprintf("%s is a new product (%s)", item.Title, item.Prices)
+	case storage.FlagLimited:
+		return fmt.Sprintf("%s is now a limited availability product (%s)", item.Title, item.Prices)
+	case storage.FlagLottery:
+		return fmt.Sprintf("%s is now offered by lottery (%s)", item.Title, item.Prices)
+	}
+	return item.Title
+}
+
+// Helper function to read ?since= as RFC 3339, defaulting to window ago
+func parseSince(w http.ResponseWriter, r *http.Request, window time.Duration) (time.Time, bool) {
+	value := r.URL.Query().Get("since")
+	if value == "" {
+		return time.Now().Add(-window), true
+	}
+
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		sendErrorResponse(w, "Invalid since. Must be an RFC 3339 timestamp.", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return since, true
+}
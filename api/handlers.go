@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"regexp"
+	"strings"
 	"time"
 
+	"ABCScraper/cache"
 	"ABCScraper/scrapers"
 
 	"github.com/gorilla/mux"
@@ -14,24 +17,68 @@ import (
 // APIResponse represents the standard API response format
 type APIResponse struct {
 	Status    string      `json:"status"`
+	Code      string      `json:"code,omitempty"`
 	Data      interface{} `json:"data,omitempty"`
 	Message   string      `json:"message,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
+
+	// Set when the live scrape failed and Data is an older cached result
+	Stale      bool `json:"stale,omitempty"`
+	AgeSeconds int  `json:"age_seconds,omitempty"`
 }
 
 // SetupRoutes configures all API routes
-func SetupRoutes(r *mux.Router) {
+func (s *Server) SetupRoutes(r *mux.Router) {
 	// Health check endpoint
-	r.HandleFunc("/health", healthHandler).Methods("GET")
+	r.HandleFunc("/health", s.healthHandler).Methods("GET")
+
+	// Prometheus metrics endpoint
+	r.HandleFunc("/metrics", s.metricsHandler).Methods("GET")
 
 	// API v1 routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 
+	// Full store directory export
+	api.HandleFunc("/stores", s.storeDirectoryHandler).Methods("GET")
+
 	// Store scraping endpoint - matches your desired format
-	api.HandleFunc("/stores/{zipcode}", scrapeStoresHandler).Methods("GET")
+	api.HandleFunc("/stores/{zipcode}", s.scrapeStoresHandler).Methods("GET")
 
 	// Product search endpoint
-	api.HandleFunc("/productsearch/{query}", scrapeProductSearchHandler).Methods("GET")
+	api.HandleFunc("/productsearch/{query}", s.scrapeProductSearchHandler).Methods("GET")
+
+	// Per-store product availability endpoint
+	api.HandleFunc("/products/{sku}/availability/{zipcode}", s.productAvailabilityHandler).Methods("GET")
+
+	// Price history endpoint, by product key or SKU
+	api.HandleFunc("/products/{id}/history", s.productHistoryHandler).Methods("GET")
+
+	// Shopping list pricing and fulfillment planner
+	api.HandleFunc("/shoppinglist", s.shoppingListHandler).Methods("POST")
+
+	// Stored catalog endpoints
+	api.HandleFunc("/catalog/products", s.catalogProductsHandler).Methods("GET")
+	api.HandleFunc("/catalog/products/{key}", s.catalogProductHandler).Methods("GET")
+	api.HandleFunc("/catalog/skus/{sku}", s.catalogVariantHandler).Methods("GET")
+	api.HandleFunc("/catalog/snapshot", s.catalogSnapshotHandler).Methods("GET")
+	api.HandleFunc("/scraperuns", s.scrapeRunsHandler).Methods("GET")
+
+	// Price watchlist endpoints
+	api.HandleFunc("/watchlist", s.listWatchesHandler).Methods("GET")
+	api.HandleFunc("/watchlist", s.createWatchHandler).Methods("POST")
+	api.HandleFunc("/watchlist/deadletters", s.deadLettersHandler).Methods("GET")
+	api.HandleFunc("/watchlist/{id:[0-9]+}", s.getWatchHandler).Methods("GET")
+	api.HandleFunc("/watchlist/{id:[0-9]+}", s.updateWatchHandler).Methods("PUT")
+	api.HandleFunc("/watchlist/{id:[0-9]+}", s.deleteWatchHandler).Methods("DELETE")
+
+	// Monthly sale catalog
+	api.HandleFunc("/sales", s.salesHandler).Methods("GET")
+
+	// Catalog change log for delta sync
+	api.HandleFunc("/changes", s.changesHandler).Methods("GET")
+
+	// New, limited and lottery release feed
+	api.HandleFunc("/feed/releases", s.releaseFeedHandler).Methods("GET")
 
 	// You can add more endpoints here as you expand
 	// api.HandleFunc("/products/{productId}", scrapeProductHandler).Methods("GET")
@@ -39,10 +86,38 @@ func SetupRoutes(r *mux.Router) {
 }
 
 // Health check handler
-func healthHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	circuits := s.upstream.Circuits()
+
+	message := "Scraper API is healthy and running"
+	for _, circuit := range circuits {
+		if circuit.State != scrapers.CircuitClosed {
+			message = "Scraper API is running but some upstream dependencies are unavailable"
+			break
+		}
+	}
+
+	data := map[string]interface{}{"circuits": circuits}
+
+	// Only reported when requests go out through a proxy pool
+	if proxies := s.upstream.Proxies(); proxies != nil {
+		data["proxies"] = proxies
+
+		available := 0
+		for _, proxy := range proxies {
+			if proxy.Healthy && !proxy.Removed {
+				available++
+			}
+		}
+		if available == 0 {
+			message = "Scraper API is running but no upstream proxy is available"
+		}
+	}
+
 	response := APIResponse{
 		Status:    "success",
-		Message:   "Scraper API is healthy and running",
+		Data:      data,
+		Message:   message,
 		Timestamp: time.Now(),
 	}
 
@@ -52,7 +127,7 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Handler for scraping stores by zip code
-func scrapeStoresHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) scrapeStoresHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract zipcode from URL path
 	vars := mux.Vars(r)
 	zipcode := vars["zipcode"]
@@ -63,31 +138,51 @@ func scrapeStoresHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Call your scraper function
-	scrapedData, err := scrapers.ScrapeUserStore(zipcode)
+	if !isValidStoreFormat(r.URL.Query().Get("format")) {
+		sendErrorResponse(w, "Invalid format. Must be json, geojson or kml.", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := requestContext(r, s.config.StoreSearchTimeout)
+	defer cancel()
+
+	// Call your scraper function, through the cache
+	result, err := s.storeCache.Do(ctx, zipcode, func(ctx context.Context) ([]scrapers.StoreResult, error) {
+		return s.stores.NearestStores(ctx, zipcode)
+	})
 	if err != nil {
-		sendErrorResponse(w, "Failed to scrape store data: "+err.Error(), http.StatusInternalServerError)
+		s.sendScrapeError(w, "Failed to scrape store data", err)
 		return
 	}
+	scrapedData := result.Value
+	setCacheHeaders(w, result)
 
 	if scrapedData == nil {
 		scrapedData = []scrapers.StoreResult{}
 	}
 
-	// Send successful response
-	response := APIResponse{
-		Status:    "success",
-		Data:      scrapedData,
-		Timestamp: time.Now(),
+	// Send successful response in the requested format
+	writeStores(w, r, "ABC stores near "+zipcode, scrapedData, result)
+}
+
+// Handler for exporting the full synced store directory
+func (s *Server) storeDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !isValidStoreFormat(r.URL.Query().Get("format")) {
+		sendErrorResponse(w, "Invalid format. Must be json, geojson or kml.", http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	stores, syncedAt := s.directory.StoreDirectory()
+	if syncedAt.IsZero() {
+		sendErrorResponse(w, "Store directory has not been synced yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeStores(w, r, "ABC stores", stores, cache.Result[[]scrapers.StoreResult]{})
 }
 
 // Handler for scraping products by search query
-func scrapeProductSearchHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) scrapeProductSearchHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract query from URL path
 	vars := mux.Vars(r)
 	query := vars["query"]
@@ -98,21 +193,114 @@ func scrapeProductSearchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Call your scraper function
-	scrapedData, err := scrapers.ScrapeProductsSearch(query)
+	ctx, cancel := requestContext(r, s.config.ProductSearchTimeout)
+	defer cancel()
+
+	// Call your scraper function, through the cache
+	result, err := s.searchCache.Do(ctx, strings.ToLower(strings.TrimSpace(query)), func(ctx context.Context) ([]scrapers.ProductResult, error) {
+		return s.products.SearchProducts(ctx, query)
+	})
 	if err != nil {
-		sendErrorResponse(w, "Failed to scrape product search data: "+err.Error(), http.StatusInternalServerError)
+		s.sendScrapeError(w, "Failed to scrape product search data", err)
 		return
 	}
+	scrapedData := result.Value
+	setCacheHeaders(w, result)
 
-	if scrapedData == nil {
-		scrapedData = []scrapers.ProductResult{}
+	// Send successful response with each variant's price history
+	response := APIResponse{
+		Status:    "success",
+		Data:      s.withPriceHistory(scrapedData),
+		Timestamp: time.Now(),
+	}
+	markStale(&response, result)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Handler for looking up which nearby stores have a product in stock
+func (s *Server) productAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sku := vars["sku"]
+	zipcode := vars["zipcode"]
+
+	if !isValidSKU(sku) {
+		sendErrorResponse(w, "Invalid SKU format. Must be 6 digits.", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidZipcode(zipcode) {
+		sendErrorResponse(w, "Invalid zipcode format. Must be 5 digits.", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := requestContext(r, s.config.AvailabilityTimeout)
+	defer cancel()
+
+	availability, err := s.inventory.ProductAvailability(ctx, sku, zipcode)
+	if err != nil {
+		s.sendScrapeError(w, "Failed to scrape product availability", err)
+		return
 	}
 
 	// Send successful response
 	response := APIResponse{
 		Status:    "success",
-		Data:      scrapedData,
+		Data:      availability,
+		Timestamp: time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ShoppingListRequest is the body accepted by the shopping list endpoint
+type ShoppingListRequest struct {
+	ZipCode string                      `json:"zipcode"`
+	Items   []scrapers.ShoppingListItem `json:"items"`
+}
+
+// Handler for pricing a shopping list and planning where to buy it
+func (s *Server) shoppingListHandler(w http.ResponseWriter, r *http.Request) {
+	var req ShoppingListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request body. Must be JSON with zipcode and items.", http.StatusBadRequest)
+		return
+	}
+
+	if !isValidZipcode(req.ZipCode) {
+		sendErrorResponse(w, "Invalid zipcode format. Must be 5 digits.", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Items) < 1 || len(req.Items) > 50 {
+		sendErrorResponse(w, "Invalid items. Must contain 1-50 items.", http.StatusBadRequest)
+		return
+	}
+
+	for _, item := range req.Items {
+		if !isValidSKU(item.SKU) || item.Quantity < 1 {
+			sendErrorResponse(w, "Invalid item. SKU must be 6 digits and quantity at least 1.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx, cancel := requestContext(r, s.config.ShoppingListTimeout)
+	defer cancel()
+
+	plan, err := s.inventory.PlanShoppingList(ctx, req.ZipCode, req.Items)
+	if err != nil {
+		s.sendScrapeError(w, "Failed to plan shopping list", err)
+		return
+	}
+
+	// Send successful response
+	response := APIResponse{
+		Status:    "success",
+		Data:      plan,
 		Timestamp: time.Now(),
 	}
 
@@ -128,6 +316,13 @@ func isValidZipcode(zipcode string) bool {
 	return matched
 }
 
+// Helper function to validate SKU format
+func isValidSKU(sku string) bool {
+	// ABC product codes are 6 digits, e.g. 038180
+	matched, _ := regexp.MatchString(`^\d{6}$`, sku)
+	return matched
+}
+
 // Helper function to validate search query
 func isValidQuery(query string) bool {
 	// Must be 1-100 characters, alphanumeric plus common punctuation and spaces
@@ -140,8 +335,14 @@ func isValidQuery(query string) bool {
 
 // Helper function to send error responses
 func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+	sendErrorCode(w, message, statusCode, statusErrorCode(statusCode))
+}
+
+// Helper function to send error responses with a specific machine-readable code
+func sendErrorCode(w http.ResponseWriter, message string, statusCode int, code string) {
 	response := APIResponse{
 		Status:    "error",
+		Code:      code,
 		Message:   message,
 		Timestamp: time.Now(),
 	}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"ABCScraper/scrapers"
+	"ABCScraper/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// VariantHistory is the recorded price history of one SKU
+type VariantHistory struct {
+	SKU     string               `json:"sku"`
+	Size    string               `json:"size"`
+	Points  []storage.PricePoint `json:"points"`
+	Summary storage.PriceSummary `json:"summary"`
+}
+
+// ProductHistory is the price history of every variant of a product
+type ProductHistory struct {
+	ProductKey string           `json:"product_key"`
+	Variants   []VariantHistory `json:"variants"`
+}
+
+// ProductPayload is a search result with the price summary of each variant
+// from the stored history, keyed by SKU
+type ProductPayload struct {
+	scrapers.ProductResult
+	PriceHistory map[string]storage.PriceSummary `json:"price_history,omitempty"`
+}
+
+// Handler for reading the price history of a product key or a single SKU
+func (s *Server) productHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	var (
+		productKey string
+		variants   []scrapers.ProductVariant
+	)
+	if isValidSKU(id) {
+		variant, err := s.repository.Variant(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			sendErrorResponse(w, "SKU not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			sendErrorResponse(w, "Failed to read stored variant: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		productKey = variant.ProductKey
+		variants = []scrapers.ProductVariant{{SKU: variant.SKU, Size: variant.Size, Price: variant.Price}}
+	} else {
+		product, err := s.repository.Product(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			sendErrorResponse(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			sendErrorResponse(w, "Failed to read stored product: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		productKey = product.Key
+		variants = product.Product.Variants
+	}
+
+	history := ProductHistory{ProductKey: productKey, Variants: []VariantHistory{}}
+	now := time.Now()
+	for _, variant := range variants {
+		points, err := s.repository.PriceHistory(variant.SKU)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			sendErrorResponse(w, "Failed to read price history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if points == nil {
+			points = []storage.PricePoint{}
+		}
+
+		history.Variants = append(history.Variants, VariantHistory{
+			SKU:     variant.SKU,
+			Size:    variant.Size,
+			Points:  points,
+			Summary: storage.SummarizePrices(points, now),
+		})
+	}
+
+	sendSuccessResponse(w, history)
+}
+
+// withPriceHistory adds the stored price summary of each variant to search results.
+// Results are returned without summaries when the server runs without storage.
+func (s *Server) withPriceHistory(products []scrapers.ProductResult) []ProductPayload {
+	payload := make([]ProductPayload, 0, len(products))
+	now := time.Now()
+
+	for _, product := range products {
+		item := ProductPayload{ProductResult: product}
+		if s.repository != nil {
+			for _, variant := range product.Variants {
+				points, err := s.repository.PriceHistory(variant.SKU)
+				if err != nil {
+					continue
+				}
+				if item.PriceHistory == nil {
+					item.PriceHistory = make(map[string]storage.PriceSummary)
+				}
+				item.PriceHistory[variant.SKU] = storage.SummarizePrices(points, now)
+			}
+		}
+		payload = append(payload, item)
+	}
+
+	return payload
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"ABCScraper/scrapers"
+)
+
+// circuitStates maps circuit breaker states to the values of the state gauge
+var circuitStates = map[string]int{
+	scrapers.CircuitClosed:   0,
+	scrapers.CircuitHalfOpen: 1,
+	scrapers.CircuitOpen:     2,
+}
+
+// Handler for Prometheus metrics in the text exposition format
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	circuits := s.upstream.Circuits()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintln(w, "# HELP abcscraper_circuit_state Upstream circuit breaker state (0 closed, 1 half-open, 2 open).")
+	fmt.Fprintln(w, "# TYPE abcscraper_circuit_state gauge")
+	for _, circuit := range circuits {
+		fmt.Fprintf(w, "abcscraper_circuit_state{dependency=%q} %d\n", circuit.Dependency, circuitStates[circuit.State])
+	}
+
+	fmt.Fprintln(w, "# HELP abcscraper_circuit_failures_total Failed calls to an upstream dependency.")
+	fmt.Fprintln(w, "# TYPE abcscraper_circuit_failures_total counter")
+	for _, circuit := range circuits {
+		fmt.Fprintf(w, "abcscraper_circuit_failures_total{dependency=%q} %d\n", circuit.Dependency, circuit.Failures)
+	}
+
+	fmt.Fprintln(w, "# HELP abcscraper_circuit_rejected_total Calls failed fast because a circuit was open.")
+	fmt.Fprintln(w, "# TYPE abcscraper_circuit_rejected_total counter")
+	for _, circuit := range circuits {
+		fmt.Fprintf(w, "abcscraper_circuit_rejected_total{dependency=%q} %d\n", circuit.Dependency, circuit.Rejected)
+	}
+
+	fmt.Fprintln(w, "# HELP abcscraper_circuit_opens_total Times a circuit has opened.")
+	fmt.Fprintln(w, "# TYPE abcscraper_circuit_opens_total counter")
+	for _, circuit := range circuits {
+		fmt.Fprintf(w, "abcscraper_circuit_opens_total{dependency=%q} %d\n", circuit.Dependency, circuit.Opens)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ABCScraper/scrapers"
+)
+
+// Handler for the monthly sale catalog. Supports ?category= to filter and
+// ?sort=discount|price|title to order the results.
+func (s *Server) salesHandler(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+	sortBy := r.URL.Query().Get("sort")
+
+	switch sortBy {
+	case "", "discount", "price", "title":
+	default:
+		sendErrorResponse(w, "Invalid sort. Must be discount, price or title.", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := requestContext(r, s.config.SalesTimeout)
+	defer cancel()
+
+	// The whole catalog is one cache entry; filtering and sorting happen per request
+	result, err := s.salesCache.Do(ctx, "catalog", func(ctx context.Context) ([]scrapers.SaleItem, error) {
+		return s.sales.Sales(ctx)
+	})
+	if err != nil {
+		s.sendScrapeError(w, "Failed to scrape sales", err)
+		return
+	}
+	setCacheHeaders(w, result)
+
+	sales := []scrapers.SaleItem{}
+	for _, item := range result.Value {
+		if category == "" || strings.EqualFold(item.Category, category) {
+			sales = append(sales, item)
+		}
+	}
+
+	switch sortBy {
+	case "", "discount":
+		sort.SliceStable(sales, func(i, j int) bool {
+			return sales[i].DiscountPercent > sales[j].DiscountPercent
+		})
+	case "price":
+		sort.SliceStable(sales, func(i, j int) bool {
+			return sales[i].SalePrice < sales[j].SalePrice
+		})
+	case "title":
+		sort.SliceStable(sales, func(i, j int) bool {
+			return sales[i].Title < sales[j].Title
+		})
+	}
+
+	response := APIResponse{
+		Status:    "success",
+		Data:      sales,
+		Timestamp: time.Now(),
+	}
+	markStale(&response, result)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ABCScraper/cache"
+	"ABCScraper/scrapers"
+	"ABCScraper/snapshot"
+	"ABCScraper/storage"
+)
+
+// ProductSearcher answers product searches for the search endpoint
+type ProductSearcher interface {
+	SearchProducts(ctx context.Context, query string) ([]scrapers.ProductResult, error)
+}
+
+// StoreLocator finds the stores nearest to a zip code for the store endpoint
+type StoreLocator interface {
+	NearestStores(ctx context.Context, zipcode string) ([]scrapers.StoreResult, error)
+}
+
+// InventoryChecker answers stock questions for the availability and shopping list endpoints
+type InventoryChecker interface {
+	ProductAvailability(ctx context.Context, sku, zipcode string) ([]scrapers.StoreAvailability, error)
+	PlanShoppingList(ctx context.Context, zipcode string, items []scrapers.ShoppingListItem) (*scrapers.ShoppingPlan, error)
+}
+
+// SaleLister lists the current sale catalog for the sales endpoint
+type SaleLister interface {
+	Sales(ctx context.Context) ([]scrapers.SaleItem, error)
+}
+
+// DirectoryReader reads the synced store directory for the store export endpoint
+type DirectoryReader interface {
+	StoreDirectory() ([]scrapers.StoreResult, time.Time)
+}
+
+// UpstreamMonitor reports the state of the upstream circuits and proxies for
+// the health and metrics endpoints
+type UpstreamMonitor interface {
+	Circuits() []scrapers.CircuitStatus
+	Proxies() []scrapers.ProxyStatus
+}
+
+// Backends are what the server answers upstream-backed requests from. Any
+// left nil are filled in with Scrapers.
+type Backends struct {
+	Products  ProductSearcher
+	Stores    StoreLocator
+	Inventory InventoryChecker
+	Sales     SaleLister
+	Directory DirectoryReader
+	Upstream  UpstreamMonitor
+}
+
+// Scrapers is the implementation of every backend that scrapes abc.virginia.gov
+type Scrapers struct{}
+
+// SearchProducts searches the live catalog through Coveo
+func (Scrapers) SearchProducts(ctx context.Context, query string) ([]scrapers.ProductResult, error) {
+	return scrapers.ScrapeProductsSearch(ctx, query)
+}
+
+// NearestStores looks up the stores nearest to a zip code
+func (Scrapers) NearestStores(ctx context.Context, zipcode string) ([]scrapers.StoreResult, error) {
+	return scrapers.ScrapeUserStore(ctx, zipcode)
+}
+
+// ProductAvailability checks a SKU's stock at the stores nearest to a zip code
+func (Scrapers) ProductAvailability(ctx context.Context, sku, zipcode string) ([]scrapers.StoreAvailability, error) {
+	return scrapers.ScrapeProductAvailability(ctx, sku, zipcode)
+}
+
+// PlanShoppingList prices a shopping list and picks the stores to buy it from
+func (Scrapers) PlanShoppingList(ctx context.Context, zipcode string, items []scrapers.ShoppingListItem) (*scrapers.ShoppingPlan, error) {
+	return scrapers.PlanShoppingList(ctx, zipcode, items)
+}
+
+// Sales crawls the monthly sale catalog
+func (Scrapers) Sales(ctx context.Context) ([]scrapers.SaleItem, error) {
+	return scrapers.ScrapeSales(ctx)
+}
+
+// StoreDirectory returns the store directory from the last sync
+func (Scrapers) StoreDirectory() ([]scrapers.StoreResult, time.Time) {
+	return scrapers.StoreDirectory()
+}
+
+// Circuits reports every upstream circuit breaker
+func (Scrapers) Circuits() []scrapers.CircuitStatus {
+	return scrapers.Circuits()
+}
+
+// Proxies reports the proxy pool, or nil when requests go out directly
+func (Scrapers) Proxies() []scrapers.ProxyStatus {
+	return scrapers.Proxies()
+}
+
+// Config holds the response cache and request deadline settings. The max
+// stale settings are how long past its TTL a cached result may still be
+// served when the live scrape fails.
+type Config struct {
+	ProductSearchCacheTTL time.Duration
+	StoreSearchCacheTTL   time.Duration
+	ProductSearchMaxStale time.Duration
+	StoreSearchMaxStale   time.Duration
+	SalesCacheTTL         time.Duration
+	SalesMaxStale         time.Duration
+	ResponseCacheSize     int
+
+	// CacheFactory creates the response cache backends, in memory unless a
+	// shared backend is configured
+	CacheFactory cache.Factory
+
+	// Deadlines for the endpoints that call upstream, covering every upstream
+	// call a request makes. A client disconnecting cancels the work sooner.
+	StoreSearchTimeout   time.Duration
+	ProductSearchTimeout time.Duration
+	AvailabilityTimeout  time.Duration
+	ShoppingListTimeout  time.Duration
+	SalesTimeout         time.Duration
+}
+
+// DefaultConfig is the configuration the server runs with unless overridden
+var DefaultConfig = Config{
+	ProductSearchCacheTTL: 10 * time.Minute,
+	StoreSearchCacheTTL:   time.Hour,
+	ProductSearchMaxStale: 24 * time.Hour,
+	StoreSearchMaxStale:   7 * 24 * time.Hour,
+	SalesCacheTTL:         time.Hour,
+	SalesMaxStale:         7 * 24 * time.Hour,
+	ResponseCacheSize:     1000,
+	CacheFactory:          cache.MemoryFactory,
+
+	StoreSearchTimeout:   15 * time.Second,
+	ProductSearchTimeout: 20 * time.Second,
+	AvailabilityTimeout:  30 * time.Second,
+	ShoppingListTimeout:  60 * time.Second,
+	SalesTimeout:         2 * time.Minute,
+}
+
+// Server serves the API from the backends and stores it is given
+type Server struct {
+	products   ProductSearcher
+	stores     StoreLocator
+	inventory  InventoryChecker
+	sales      SaleLister
+	directory  DirectoryReader
+	upstream   UpstreamMonitor
+	repository storage.Repository
+	snapshots  *snapshot.Store
+	config     Config
+	logger     *log.Logger
+
+	// Caches in front of the backends, keyed by normalized query or zipcode.
+	// The sale catalog is a single entry, since every request crawls the same pages.
+	searchCache *cache.Cache[[]scrapers.ProductResult]
+	storeCache  *cache.Cache[[]scrapers.StoreResult]
+	salesCache  *cache.Cache[[]scrapers.SaleItem]
+}
+
+// NewServer creates a server and its response caches. repo and snapshots may
+// be nil, in which case the endpoints that need them report that they are not
+// configured. A nil logger logs through the standard logger.
+func NewServer(config Config, backends Backends, repo storage.Repository, snapshots *snapshot.Store, logger *log.Logger) *Server {
+	if backends.Products == nil {
+		backends.Products = Scrapers{}
+	}
+	if backends.Stores == nil {
+		backends.Stores = Scrapers{}
+	}
+	if backends.Inventory == nil {
+		backends.Inventory = Scrapers{}
+	}
+	if backends.Sales == nil {
+		backends.Sales = Scrapers{}
+	}
+	if backends.Directory == nil {
+		backends.Directory = Scrapers{}
+	}
+	if backends.Upstream == nil {
+		backends.Upstream = Scrapers{}
+	}
+	if config.CacheFactory == nil {
+		config.CacheFactory = cache.MemoryFactory
+	}
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &Server{
+		products:    backends.Products,
+		stores:      backends.Stores,
+		inventory:   backends.Inventory,
+		sales:       backends.Sales,
+		directory:   backends.Directory,
+		upstream:    backends.Upstream,
+		repository:  repo,
+		snapshots:   snapshots,
+		config:      config,
+		logger:      logger,
+		searchCache: cache.New[[]scrapers.ProductResult](config.CacheFactory("search", config.ResponseCacheSize), config.ProductSearchCacheTTL, config.ProductSearchMaxStale),
+		storeCache:  cache.New[[]scrapers.StoreResult](config.CacheFactory("stores", config.ResponseCacheSize), config.StoreSearchCacheTTL, config.StoreSearchMaxStale),
+		salesCache:  cache.New[[]scrapers.SaleItem](config.CacheFactory("sales", 1), config.SalesCacheTTL, config.SalesMaxStale),
+	}
+}
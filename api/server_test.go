@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ABCScraper/scrapers"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeBackends answers every backend from canned results, counting calls
+type fakeBackends struct {
+	products []scrapers.ProductResult
+	stores   []scrapers.StoreResult
+	sales    []scrapers.SaleItem
+	err      error
+	calls    int
+
+	directory []scrapers.StoreResult
+	syncedAt  time.Time
+	circuits  []scrapers.CircuitStatus
+	proxies   []scrapers.ProxyStatus
+}
+
+func (f *fakeBackends) SearchProducts(ctx context.Context, query string) ([]scrapers.ProductResult, error) {
+	f.calls++
+	return f.products, f.err
+}
+
+func (f *fakeBackends) NearestStores(ctx context.Context, zipcode string) ([]scrapers.StoreResult, error) {
+	f.calls++
+	return f.stores, f.err
+}
+
+func (f *fakeBackends) ProductAvailability(ctx context.Context, sku, zipcode string) ([]scrapers.StoreAvailability, error) {
+	f.calls++
+	return []scrapers.StoreAvailability{{StoreResult: scrapers.StoreResult{StoreNumber: "45"}, SKU: sku, Quantity: 3, InStock: true}}, f.err
+}
+
+func (f *fakeBackends) PlanShoppingList(ctx context.Context, zipcode string, items []scrapers.ShoppingListItem) (*scrapers.ShoppingPlan, error) {
+	f.calls++
+	return &scrapers.ShoppingPlan{}, f.err
+}
+
+func (f *fakeBackends) Sales(ctx context.Context) ([]scrapers.SaleItem, error) {
+	f.calls++
+	return f.sales, f.err
+}
+
+func (f *fakeBackends) StoreDirectory() ([]scrapers.StoreResult, time.Time) {
+	return f.directory, f.syncedAt
+}
+
+func (f *fakeBackends) Circuits() []scrapers.CircuitStatus {
+	return f.circuits
+}
+
+func (f *fakeBackends) Proxies() []scrapers.ProxyStatus {
+	return f.proxies
+}
+
+// newTestServer routes requests to a server on fake backends, with config
+// adjusted by configure if it is not nil
+func newTestServer(t *testing.T, fake *fakeBackends, configure func(*Config)) http.Handler {
+	t.Helper()
+
+	config := DefaultConfig
+	if configure != nil {
+		configure(&config)
+	}
+	server := NewServer(config, Backends{Products: fake, Stores: fake, Inventory: fake, Sales: fake, Directory: fake, Upstream: fake}, nil, nil, nil)
+
+	r := mux.NewRouter()
+	server.SetupRoutes(r)
+	return r
+}
+
+// get sends a GET request to handler and decodes the APIResponse
+func get(t *testing.T, handler http.Handler, path string) (*httptest.ResponseRecorder, APIResponse) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var response APIResponse
+	if strings.Contains(recorder.Header().Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatalf("GET %s: decoding response: %v", path, err)
+		}
+	}
+	return recorder, response
+}
+
+func TestProductSearch(t *testing.T) {
+	fake := &fakeBackends{products: []scrapers.ProductResult{{Title: "Tito's Handmade Vodka", ProductID: "010807"}}}
+	handler := newTestServer(t, fake, nil)
+
+	recorder, response := get(t, handler, "/api/v1/productsearch/Titos")
+	if recorder.Code != http.StatusOK || response.Status != "success" {
+		t.Fatalf("status %d, response %+v", recorder.Code, response)
+	}
+	if recorder.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("X-Cache = %q, want MISS", recorder.Header().Get("X-Cache"))
+	}
+	if data := fmt.Sprint(response.Data); !strings.Contains(data, "Tito's Handmade Vodka") {
+		t.Errorf("data = %s, want the fake product", data)
+	}
+
+	// The same query, normalized, is answered from the cache
+	recorder, _ = get(t, handler, "/api/v1/productsearch/titos")
+	if recorder.Header().Get("X-Cache") != "HIT" || fake.calls != 1 {
+		t.Errorf("X-Cache = %q after %d backend calls, want a HIT after 1", recorder.Header().Get("X-Cache"), fake.calls)
+	}
+}
+
+func TestScrapeErrorsMapToStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		path   string
+		status int
+		code   string
+	}{
+		{fmt.Errorf("zip 00000: %w", scrapers.ErrGeocodeNotFound), "/api/v1/stores/00000", http.StatusNotFound, "geocode_not_found"},
+		{fmt.Errorf("coveo: %w", scrapers.ErrTokenExpired), "/api/v1/productsearch/bourbon", http.StatusUnauthorized, "token_expired"},
+		{fmt.Errorf("coveo: %w", scrapers.ErrUpstreamTimeout), "/api/v1/sales", http.StatusGatewayTimeout, "upstream_timeout"},
+		{fmt.Errorf("inventory: %w", scrapers.ErrUpstreamFailed), "/api/v1/products/010807/availability/23220", http.StatusBadGateway, "upstream_failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			handler := newTestServer(t, &fakeBackends{err: tt.err}, nil)
+
+			recorder, response := get(t, handler, tt.path)
+			if recorder.Code != tt.status || response.Code != tt.code {
+				t.Errorf("GET %s = %d %q, want %d %q", tt.path, recorder.Code, response.Code, tt.status, tt.code)
+			}
+			if strings.Contains(response.Message, "zip 00000") {
+				t.Errorf("message %q leaks the wrapped error", response.Message)
+			}
+		})
+	}
+}
+
+func TestStaleResultServedWhenBackendFails(t *testing.T) {
+	fake := &fakeBackends{stores: []scrapers.StoreResult{{Title: "Broad St", StoreNumber: "45"}}}
+	handler := newTestServer(t, fake, func(config *Config) {
+		config.StoreSearchCacheTTL = time.Millisecond
+		config.StoreSearchMaxStale = time.Hour
+	})
+
+	if recorder, _ := get(t, handler, "/api/v1/stores/23220"); recorder.Code != http.StatusOK {
+		t.Fatalf("priming request: status %d", recorder.Code)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	fake.err = fmt.Errorf("coveo: %w", scrapers.ErrUpstreamFailed)
+	recorder, response := get(t, handler, "/api/v1/stores/23220")
+	if recorder.Code != http.StatusOK || !response.Stale {
+		t.Fatalf("status %d, response %+v, want the stale stores", recorder.Code, response)
+	}
+	if recorder.Header().Get("X-Cache") != "STALE" || recorder.Header().Get("Warning") == "" {
+		t.Errorf("headers %v, want X-Cache STALE and a Warning", recorder.Header())
+	}
+	if data := fmt.Sprint(response.Data); !strings.Contains(data, "Broad St") {
+		t.Errorf("data = %s, want the cached store", data)
+	}
+}
+
+func TestStoresAsGeoJSON(t *testing.T) {
+	fake := &fakeBackends{stores: []scrapers.StoreResult{{Title: "Broad St", Latitude: 37.55, Longitude: -77.46}}}
+	handler := newTestServer(t, fake, nil)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/stores/23220?format=geojson", nil))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "application/geo+json" {
+		t.Fatalf("status %d, content type %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Coordinates [2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&collection); err != nil {
+		t.Fatalf("decoding GeoJSON: %v", err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 1 {
+		t.Fatalf("collection = %+v, want one feature", collection)
+	}
+	feature := collection.Features[0]
+	if feature.Geometry.Coordinates != [2]float64{-77.46, 37.55} || feature.Properties["title"] != "Broad St" {
+		t.Errorf("feature = %+v, want Broad St at longitude, latitude", feature)
+	}
+}
+
+func TestSalesAreCachedAndFiltered(t *testing.T) {
+	fake := &fakeBackends{sales: []scrapers.SaleItem{
+		{Title: "Buffalo Trace", Category: "Bourbon", SalePrice: 26.99, DiscountPercent: 10},
+		{Title: "Tito's", Category: "Vodka", SalePrice: 19.99, DiscountPercent: 20},
+		{Title: "Maker's Mark", Category: "Bourbon", SalePrice: 24.99, DiscountPercent: 15},
+	}}
+	handler := newTestServer(t, fake, nil)
+
+	get(t, handler, "/api/v1/sales")
+	recorder, response := get(t, handler, "/api/v1/sales?category=bourbon&sort=price")
+	if recorder.Code != http.StatusOK || fake.calls != 1 {
+		t.Fatalf("status %d after %d backend calls, want 200 after 1", recorder.Code, fake.calls)
+	}
+
+	data, _ := json.Marshal(response.Data)
+	var sales []scrapers.SaleItem
+	json.Unmarshal(data, &sales)
+	if len(sales) != 2 || sales[0].Title != "Maker's Mark" || sales[1].Title != "Buffalo Trace" {
+		t.Errorf("sales = %+v, want the bourbons cheapest first", sales)
+	}
+}
+
+func TestShoppingListUsesInventoryBackend(t *testing.T) {
+	fake := &fakeBackends{}
+	handler := newTestServer(t, fake, nil)
+
+	body := `{"zipcode": "23220", "items": [{"sku": "010807", "quantity": 1}]}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/shoppinglist", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK || fake.calls != 1 {
+		t.Errorf("status %d after %d backend calls, want 200 after 1", recorder.Code, fake.calls)
+	}
+}
+
+func TestHealthReportsUpstreamState(t *testing.T) {
+	fake := &fakeBackends{circuits: []scrapers.CircuitStatus{{Dependency: "coveo", State: scrapers.CircuitClosed}}}
+	handler := newTestServer(t, fake, nil)
+
+	recorder, response := get(t, handler, "/health")
+	if recorder.Code != http.StatusOK || response.Message != "Scraper API is healthy and running" {
+		t.Fatalf("status %d, message %q", recorder.Code, response.Message)
+	}
+
+	fake.circuits[0].State = scrapers.CircuitOpen
+	if _, response := get(t, handler, "/health"); !strings.Contains(response.Message, "upstream dependencies are unavailable") {
+		t.Errorf("message with an open circuit = %q", response.Message)
+	}
+
+	fake.circuits[0].State = scrapers.CircuitClosed
+	fake.proxies = []scrapers.ProxyStatus{{Proxy: "http://proxy:8080", Healthy: true, Removed: true}}
+	_, response = get(t, handler, "/health")
+	if !strings.Contains(response.Message, "no upstream proxy is available") {
+		t.Errorf("message with every proxy removed = %q", response.Message)
+	}
+	if data := fmt.Sprint(response.Data); !strings.Contains(data, "proxy:8080") {
+		t.Errorf("data = %s, want the proxy pool", data)
+	}
+}
+
+func TestMetricsReportCircuits(t *testing.T) {
+	fake := &fakeBackends{circuits: []scrapers.CircuitStatus{{Dependency: "nominatim", State: scrapers.CircuitHalfOpen, Failures: 7}}}
+	handler := newTestServer(t, fake, nil)
+
+	recorder, _ := get(t, handler, "/metrics")
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`abcscraper_circuit_state{dependency="nominatim"} 1`,
+		`abcscraper_circuit_failures_total{dependency="nominatim"} 7`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestStoreDirectoryExport(t *testing.T) {
+	fake := &fakeBackends{}
+	handler := newTestServer(t, fake, nil)
+
+	if recorder, _ := get(t, handler, "/api/v1/stores"); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("unsynced directory: status %d, want 503", recorder.Code)
+	}
+
+	fake.directory = []scrapers.StoreResult{{Title: "Broad St", StoreNumber: "45"}}
+	fake.syncedAt = time.Now()
+	recorder, response := get(t, handler, "/api/v1/stores")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, response %+v", recorder.Code, response)
+	}
+	if data := fmt.Sprint(response.Data); !strings.Contains(data, "Broad St") {
+		t.Errorf("data = %s, want the synced store", data)
+	}
+}
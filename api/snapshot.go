@@ -0,0 +1,88 @@
+package api
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"ABCScraper/snapshot"
+)
+
+// Handler for downloading the latest offline catalog snapshot. Clients send the
+// ETag they have in If-None-Match and get 304 Not Modified when it is current.
+func (s *Server) catalogSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if s.snapshots == nil {
+		sendErrorResponse(w, "Catalog snapshots are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	latest, err := s.snapshots.Latest()
+	if errors.Is(err, snapshot.ErrNoSnapshot) {
+		sendErrorResponse(w, "No catalog snapshot has been built yet", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to read catalog snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The gzip and identity bodies are different representations, so each
+	// gets its own strong ETag
+	acceptsGzip := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+	etag := `"` + latest.ETag + `"`
+	if acceptsGzip {
+		etag = `"` + latest.ETag + `-gzip"`
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Catalog-Version", strconv.FormatInt(latest.Version, 10))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Accept-Encoding")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	file, err := os.Open(latest.Path)
+	if err != nil {
+		sendErrorResponse(w, "Failed to open catalog snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Snapshots are stored compressed; only inflate them for clients that can't take gzip
+	if acceptsGzip {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", strconv.FormatInt(latest.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, file)
+		return
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read catalog snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer gz.Close()
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, gz)
+}
+
+// Helper function to check an If-None-Match header against an ETag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
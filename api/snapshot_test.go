@@ -0,0 +1,89 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ABCScraper/scrapers"
+	"ABCScraper/snapshot"
+
+	"github.com/gorilla/mux"
+)
+
+// Helper function to request the snapshot with the given headers
+func getSnapshot(handler http.Handler, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/catalog/snapshot", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestCatalogSnapshot(t *testing.T) {
+	snapshots, err := snapshot.NewStore(t.TempDir(), 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	server := NewServer(DefaultConfig, Backends{}, nil, snapshots, nil)
+	r := mux.NewRouter()
+	server.SetupRoutes(r)
+
+	if recorder := getSnapshot(r, nil); recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("before the first build: status %d, want 503", recorder.Code)
+	}
+
+	if _, err := snapshots.Build([]scrapers.ProductResult{{Title: "Buffalo Trace", ProductID: "018006"}}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	plain := getSnapshot(r, nil)
+	if plain.Code != http.StatusOK || plain.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("identity: status %d, encoding %q", plain.Code, plain.Header().Get("Content-Encoding"))
+	}
+	var catalog snapshot.Catalog
+	if err := json.NewDecoder(plain.Body).Decode(&catalog); err != nil || len(catalog.Products) != 1 {
+		t.Fatalf("identity body = %+v, %v", catalog, err)
+	}
+
+	compressed := getSnapshot(r, map[string]string{"Accept-Encoding": "gzip"})
+	if compressed.Code != http.StatusOK || compressed.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip: status %d, encoding %q", compressed.Code, compressed.Header().Get("Content-Encoding"))
+	}
+	if _, err := gzip.NewReader(compressed.Body); err != nil {
+		t.Errorf("gzip body: %v", err)
+	}
+
+	plainETag, gzipETag := plain.Header().Get("ETag"), compressed.Header().Get("ETag")
+	if plainETag == "" || plainETag == gzipETag {
+		t.Fatalf("ETags %q and %q, want one per encoding", plainETag, gzipETag)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"identity current", map[string]string{"If-None-Match": plainETag}, http.StatusNotModified},
+		{"gzip current", map[string]string{"If-None-Match": gzipETag, "Accept-Encoding": "gzip"}, http.StatusNotModified},
+		{"weak and listed", map[string]string{"If-None-Match": `"old", W/` + plainETag}, http.StatusNotModified},
+		{"gzip ETag for identity", map[string]string{"If-None-Match": gzipETag}, http.StatusOK},
+		{"outdated", map[string]string{"If-None-Match": `"old"`}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := getSnapshot(r, tt.headers)
+			if recorder.Code != tt.status {
+				t.Errorf("status %d, want %d", recorder.Code, tt.status)
+			}
+			if tt.status == http.StatusNotModified && recorder.Body.Len() != 0 {
+				t.Errorf("304 carried a %d byte body", recorder.Body.Len())
+			}
+		})
+	}
+}
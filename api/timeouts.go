@@ -0,0 +1,12 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Helper function to derive a request's upstream context with an endpoint deadline
+func requestContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), timeout)
+}
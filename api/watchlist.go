@@ -0,0 +1,211 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"strconv"
+	"time"
+
+	"ABCScraper/alerts"
+	"ABCScraper/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// WatchRequest is the body accepted when creating or updating a watch
+type WatchRequest struct {
+	SKU         string  `json:"sku"`
+	Threshold   float64 `json:"threshold"`
+	OnSale      bool    `json:"on_sale"`
+	CallbackURL string  `json:"callback_url"`
+}
+
+// Handler for listing every watch
+func (s *Server) listWatchesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	watches, err := s.repository.Watches()
+	if err != nil {
+		sendErrorResponse(w, "Failed to read watchlist: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Secrets are only shown once, when the watch is created
+	for i := range watches {
+		watches[i].Secret = ""
+	}
+	if watches == nil {
+		watches = []storage.Watch{}
+	}
+
+	sendSuccessResponse(w, watches)
+}
+
+// Handler for creating a watch. The response includes the secret used to sign its webhooks.
+func (s *Server) createWatchHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	req, ok := decodeWatchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		sendErrorResponse(w, "Failed to generate webhook secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	watch := &storage.Watch{
+		SKU:         req.SKU,
+		Threshold:   req.Threshold,
+		OnSale:      req.OnSale,
+		CallbackURL: req.CallbackURL,
+		Secret:      hex.EncodeToString(secret),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repository.SaveWatch(watch); err != nil {
+		sendErrorResponse(w, "Failed to save watch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := APIResponse{
+		Status:    "success",
+		Data:      watch,
+		Timestamp: time.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Handler for reading one watch
+func (s *Server) getWatchHandler(w http.ResponseWriter, r *http.Request) {
+	watch, ok := s.loadWatch(w, r)
+	if !ok {
+		return
+	}
+
+	watch.Secret = ""
+	sendSuccessResponse(w, watch)
+}
+
+// Handler for replacing a watch's SKU, threshold, sale flag and callback
+func (s *Server) updateWatchHandler(w http.ResponseWriter, r *http.Request) {
+	watch, ok := s.loadWatch(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := decodeWatchRequest(w, r)
+	if !ok {
+		return
+	}
+
+	watch.SKU = req.SKU
+	watch.Threshold = req.Threshold
+	watch.OnSale = req.OnSale
+	watch.CallbackURL = req.CallbackURL
+	watch.Triggered = false
+
+	if err := s.repository.SaveWatch(watch); err != nil {
+		sendErrorResponse(w, "Failed to save watch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	watch.Secret = ""
+	sendSuccessResponse(w, watch)
+}
+
+// Handler for deleting a watch
+func (s *Server) deleteWatchHandler(w http.ResponseWriter, r *http.Request) {
+	watch, ok := s.loadWatch(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.repository.DeleteWatch(watch.ID); err != nil {
+		sendErrorResponse(w, "Failed to delete watch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for listing webhooks that could not be delivered
+func (s *Server) deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.requireRepository(w) {
+		return
+	}
+
+	letters, err := s.repository.DeadLetters(100)
+	if err != nil {
+		sendErrorResponse(w, "Failed to read dead letters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if letters == nil {
+		letters = []storage.DeadLetter{}
+	}
+
+	sendSuccessResponse(w, letters)
+}
+
+// Helper function to load the watch named by the {id} route variable
+func (s *Server) loadWatch(w http.ResponseWriter, r *http.Request) (*storage.Watch, bool) {
+	if !s.requireRepository(w) {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		sendErrorResponse(w, "Invalid watch id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	watch, err := s.repository.Watch(id)
+	if errors.Is(err, storage.ErrNotFound) {
+		sendErrorResponse(w, "Watch not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		sendErrorResponse(w, "Failed to read watch: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return watch, true
+}
+
+// Helper function to decode and validate a watch request body
+func decodeWatchRequest(w http.ResponseWriter, r *http.Request) (WatchRequest, bool) {
+	var req WatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid request body. Must be JSON with sku, threshold or on_sale, and callback_url.", http.StatusBadRequest)
+		return req, false
+	}
+
+	if !isValidSKU(req.SKU) {
+		sendErrorResponse(w, "Invalid SKU format. Must be 6 digits.", http.StatusBadRequest)
+		return req, false
+	}
+
+	if req.Threshold < 0 || (req.Threshold == 0 && !req.OnSale) {
+		sendErrorResponse(w, "Invalid watch. Must set a positive threshold, on_sale, or both.", http.StatusBadRequest)
+		return req, false
+	}
+
+	if err := alerts.CheckCallbackURL(r.Context(), req.CallbackURL); err != nil {
+		sendErrorResponse(w, "Invalid callback_url. Must be an absolute http or https URL on a public address: "+err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+
+	return req, true
+}
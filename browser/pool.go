@@ -0,0 +1,282 @@
+// Package browser runs chromedp tasks on a pool of long-lived headless
+// Chrome instances, reusing one tab per browser and replacing browsers that
+// crash, hang or grow too old or too large.
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// ErrClosed is returned by Run after the pool has been closed
+var ErrClosed = errors.New("browser pool is closed")
+
+// Config controls the size of the pool and the limits on each browser
+type Config struct {
+	// Number of browsers, and so the number of tasks that can run at once
+	Size int
+
+	// Show Chrome's window instead of running headless, to watch a task
+	Headful bool
+
+	// Longest a single task may run before it is cancelled
+	TaskTimeout time.Duration
+
+	// A browser is replaced after this many tasks or this long, whichever comes first
+	MaxTasks int
+	MaxAge   time.Duration
+
+	// A browser is replaced once its tab's JavaScript heap exceeds this many bytes
+	MaxHeapBytes float64
+
+	// User agent the browsers present
+	UserAgent string
+
+	// Chrome binary to run. Empty looks for Chrome or Chromium on the PATH.
+	ExecPath string
+}
+
+// DefaultConfig is a small headless pool suitable for occasional fallbacks
+var DefaultConfig = Config{
+	Size:         2,
+	TaskTimeout:  60 * time.Second,
+	MaxTasks:     100,
+	MaxAge:       30 * time.Minute,
+	MaxHeapBytes: 512 << 20,
+}
+
+// Pool hands out browser tabs to tasks
+type Pool struct {
+	config Config
+
+	// slots holds one entry per browser; nil until the browser is first needed
+	slots chan *instance
+
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+// instance is one running Chrome with the tab tasks are run in
+type instance struct {
+	tab       context.Context
+	cancel    context.CancelFunc
+	startedAt time.Time
+	tasks     int
+}
+
+// NewPool creates a pool. Browsers are started the first time they are needed.
+func NewPool(config Config) *Pool {
+	config.Size = max(config.Size, 1)
+	if config.TaskTimeout <= 0 {
+		config.TaskTimeout = DefaultConfig.TaskTimeout
+	}
+
+	p := &Pool{
+		config: config,
+		slots:  make(chan *instance, config.Size),
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < config.Size; i++ {
+		p.slots <- nil
+	}
+	return p
+}
+
+// Run runs task in a pooled browser tab. The task's context is cancelled when
+// ctx is done or the task timeout passes. If the browser dies during the task
+// it is restarted and the task is retried once.
+func (p *Pool) Run(ctx context.Context, task func(ctx context.Context) error) error {
+	var inst *instance
+	select {
+	case inst = <-p.slots:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrClosed
+	}
+
+	for attempt := 0; ; attempt++ {
+		var err error
+		inst, err = p.ready(inst)
+		if err != nil {
+			p.slots <- nil
+			return err
+		}
+
+		err = p.runTask(ctx, inst, task)
+		inst.tasks++
+
+		// The browser itself went away, not just the task
+		if inst.tab.Err() != nil {
+			log.Printf("browser pool: browser crashed after %d tasks: %v", inst.tasks, err)
+			inst.cancel()
+			inst = nil
+			if attempt == 0 && ctx.Err() == nil {
+				continue
+			}
+		}
+
+		p.release(inst)
+		return err
+	}
+}
+
+// ready returns a running browser for a slot, starting a new one if the slot
+// is empty or its browser has reached one of its limits
+func (p *Pool) ready(inst *instance) (*instance, error) {
+	if inst != nil && p.expired(inst) {
+		inst.cancel()
+		inst = nil
+	}
+	if inst != nil {
+		return inst, nil
+	}
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	return p.start()
+}
+
+// start launches a browser and opens the tab tasks will share
+func (p *Pool) start() (*instance, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", !p.config.Headful),
+		chromedp.Flag("disable-blink-features", "AutomationControlled"),
+		chromedp.Flag("exclude-switches", "enable-automation"),
+
+		// Containers often have a tiny /dev/shm and no GPU
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.DisableGPU,
+	)
+	if p.config.UserAgent != "" {
+		opts = append(opts, chromedp.UserAgent(p.config.UserAgent))
+	}
+	if p.config.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(p.config.ExecPath))
+	}
+
+	// Chrome refuses to sandbox itself when run as root
+	if os.Geteuid() == 0 {
+		opts = append(opts, chromedp.NoSandbox)
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	tab, cancelTab := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelTab()
+		cancelAlloc()
+	}
+
+	// The first Run on the tab context starts the browser
+	if err := chromedp.Run(tab); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+
+	return &instance{tab: tab, cancel: cancel, startedAt: time.Now()}, nil
+}
+
+// runTask runs task with a context derived from the instance's tab
+func (p *Pool) runTask(ctx context.Context, inst *instance, task func(ctx context.Context) error) error {
+	taskCtx, cancel := context.WithTimeout(inst.tab, p.config.TaskTimeout)
+	defer cancel()
+
+	// Stop the task early if the caller gives up
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	return task(taskCtx)
+}
+
+// expired reports whether a browser has reached its task, age or heap limit
+func (p *Pool) expired(inst *instance) bool {
+	if p.config.MaxTasks > 0 && inst.tasks >= p.config.MaxTasks {
+		return true
+	}
+	if p.config.MaxAge > 0 && time.Since(inst.startedAt) >= p.config.MaxAge {
+		return true
+	}
+	if p.config.MaxHeapBytes > 0 {
+		ctx, cancel := context.WithTimeout(inst.tab, 5*time.Second)
+		defer cancel()
+
+		var used float64
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			used, _, _, _, err = runtime.GetHeapUsage().Do(ctx)
+			return err
+		}))
+		if err != nil || used > p.config.MaxHeapBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// release resets the tab so the next task starts from a blank page and
+// returns the browser to the pool, replacing it if the tab is stuck
+func (p *Pool) release(inst *instance) {
+	if inst != nil {
+		ctx, cancel := context.WithTimeout(inst.tab, 10*time.Second)
+		err := chromedp.Run(ctx, chromedp.Navigate("about:blank"))
+		cancel()
+		if err != nil {
+			log.Printf("browser pool: replacing browser whose tab could not be reset: %v", err)
+			inst.cancel()
+			inst = nil
+		}
+	}
+
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed && inst != nil {
+		inst.cancel()
+		inst = nil
+	}
+
+	p.slots <- inst
+}
+
+// Close stops every idle browser and makes later calls to Run fail. Browsers
+// busy with a task are stopped when the task returns.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	var idle int
+	for drained := false; !drained; {
+		select {
+		case inst := <-p.slots:
+			if inst != nil {
+				inst.cancel()
+			}
+			idle++
+		default:
+			drained = true
+		}
+	}
+	for i := 0; i < idle; i++ {
+		p.slots <- nil
+	}
+}
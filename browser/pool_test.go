@@ -0,0 +1,114 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// chromePath finds a Chrome or Chromium binary to run the browser tests with,
+// from CHROME_PATH or the PATH
+func chromePath() string {
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		return path
+	}
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "headless-shell"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func TestExpired(t *testing.T) {
+	// A tab that is not a live chromedp context cannot report its heap
+	deadTab, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		config Config
+		inst   instance
+		want   bool
+	}{
+		{"under every limit", Config{MaxTasks: 3, MaxAge: time.Hour}, instance{tasks: 2, startedAt: time.Now()}, false},
+		{"task limit", Config{MaxTasks: 3, MaxAge: time.Hour}, instance{tasks: 3, startedAt: time.Now()}, true},
+		{"age limit", Config{MaxTasks: 3, MaxAge: time.Hour}, instance{tasks: 1, startedAt: time.Now().Add(-2 * time.Hour)}, true},
+		{"no limits", Config{}, instance{tasks: 1000, startedAt: time.Now().Add(-24 * time.Hour)}, false},
+		{"heap unreadable", Config{MaxHeapBytes: 1 << 20}, instance{tab: deadTab, startedAt: time.Now()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPool(tt.config)
+			if got := p.expired(&tt.inst); got != tt.want {
+				t.Errorf("expired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAfterClose(t *testing.T) {
+	p := NewPool(Config{Size: 1})
+	p.Close()
+
+	err := p.Run(context.Background(), func(ctx context.Context) error { return nil })
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Run after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestRunRetriesAfterCrash(t *testing.T) {
+	execPath := chromePath()
+	if execPath == "" {
+		t.Skip("no Chrome or Chromium found; set CHROME_PATH to run the browser tests")
+	}
+
+	p := NewPool(Config{Size: 1, TaskTimeout: 30 * time.Second, ExecPath: execPath})
+	defer p.Close()
+
+	var browsers []*chromedp.Browser
+	err := p.Run(context.Background(), func(ctx context.Context) error {
+		browsers = append(browsers, chromedp.FromContext(ctx).Browser)
+
+		// Take the whole browser down on the first attempt only
+		if len(browsers) == 1 {
+			return chromedp.Cancel(ctx)
+		}
+		return chromedp.Run(ctx, chromedp.Navigate("about:blank"))
+	})
+	if err != nil {
+		t.Fatalf("Run = %v, want the retry to succeed", err)
+	}
+	if len(browsers) != 2 || browsers[0] == browsers[1] {
+		t.Fatalf("task ran in %d browsers, want a retry in a new one", len(browsers))
+	}
+}
+
+func TestRunReplacesBrowserAtTaskLimit(t *testing.T) {
+	execPath := chromePath()
+	if execPath == "" {
+		t.Skip("no Chrome or Chromium found; set CHROME_PATH to run the browser tests")
+	}
+
+	p := NewPool(Config{Size: 1, MaxTasks: 2, TaskTimeout: 30 * time.Second, ExecPath: execPath})
+	defer p.Close()
+
+	var browsers []*chromedp.Browser
+	for range 3 {
+		err := p.Run(context.Background(), func(ctx context.Context) error {
+			browsers = append(browsers, chromedp.FromContext(ctx).Browser)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+	if browsers[0] != browsers[1] || browsers[1] == browsers[2] {
+		t.Errorf("browsers %p, want the first two tasks to share one and the third to get a new one", browsers)
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import "time"
+
+// Backend stores encoded cache entries. Implementations must be safe for
+// concurrent use; a shared backend lets several API replicas share one cache.
+type Backend interface {
+	// Get returns the entry stored under key, if it has not expired
+	Get(key string) (Entry, bool, error)
+
+	// Set stores entry under key, keeping it for the given duration
+	Set(key string, entry Entry, keep time.Duration) error
+}
+
+// Entry is an encoded value and when it was stored
+type Entry struct {
+	Value    []byte    `json:"value"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+// Factory creates the backend for a named cache holding up to capacity entries.
+// Shared backends may ignore capacity and leave eviction to the server.
+type Factory func(name string, capacity int) Backend
+
+// MemoryFactory is a Factory giving each cache its own in-memory backend
+func MemoryFactory(name string, capacity int) Backend {
+	return NewMemory(capacity)
+}
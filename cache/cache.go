@@ -0,0 +1,208 @@
+// Package cache is a TTL cache over a pluggable Backend that coalesces
+// concurrent loads of the same key into a single call and can fall back to
+// stale entries when a load fails.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevalidateAfter is how long after a failed load a stale entry is refreshed in the background
+var RevalidateAfter = 30 * time.Second
+
+// Cache holds values of type T in a Backend. Entries are fresh for ttl and
+// kept for up to maxStale after that to stand in when a load fails.
+type Cache[T any] struct {
+	backend  Backend
+	ttl      time.Duration
+	maxStale time.Duration
+
+	mu           sync.Mutex
+	calls        map[string]*call[T]
+	revalidating map[string]bool
+}
+
+// Result is a value returned by Do
+type Result[T any] struct {
+	Value T
+
+	// How long ago the value was loaded, zero for a fresh load
+	Age time.Duration
+
+	// Whether the value came from the cache rather than a load
+	Hit bool
+
+	// Whether the value is past its TTL and was served because the load failed
+	Stale bool
+}
+
+// call is an in-flight load that later callers for the same key wait on
+type call[T any] struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+	value   T
+	err     error
+}
+
+// New creates a Cache on backend. A maxStale of zero disables serving stale entries.
+func New[T any](backend Backend, ttl, maxStale time.Duration) *Cache[T] {
+	return &Cache[T]{
+		backend:      backend,
+		ttl:          ttl,
+		maxStale:     maxStale,
+		calls:        make(map[string]*call[T]),
+		revalidating: make(map[string]bool),
+	}
+}
+
+// Get returns a fresh value for key and how long ago it was stored
+func (c *Cache[T]) Get(key string) (T, time.Duration, bool) {
+	value, age, ok := c.get(key)
+	if !ok || age > c.ttl {
+		var zero T
+		return zero, 0, false
+	}
+	return value, age, true
+}
+
+// get returns the value for key whether fresh or stale. Backend failures are
+// logged and treated as a miss so a broken shared cache only costs speed.
+func (c *Cache[T]) get(key string) (T, time.Duration, bool) {
+	var value T
+
+	entry, ok, err := c.backend.Get(key)
+	if err != nil {
+		log.Printf("cache: %v", err)
+		return value, 0, false
+	}
+	if !ok {
+		return value, 0, false
+	}
+
+	age := time.Since(entry.StoredAt)
+	if age > c.ttl+c.maxStale {
+		return value, 0, false
+	}
+	if err := json.Unmarshal(entry.Value, &value); err != nil {
+		log.Printf("cache: failed to decode %s: %v", key, err)
+		return value, 0, false
+	}
+	return value, age, true
+}
+
+// Set stores value under key
+func (c *Cache[T]) Set(key string, value T) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("cache: failed to encode %s: %v", key, err)
+		return
+	}
+
+	entry := Entry{Value: data, StoredAt: time.Now()}
+	if err := c.backend.Set(key, entry, c.ttl+c.maxStale); err != nil {
+		log.Printf("cache: %v", err)
+	}
+}
+
+// Do returns the fresh cached value for key, or calls load to fill it.
+// Concurrent callers for the same key share one load, which is cancelled once
+// every caller waiting on it has given up. When the load fails and a stale
+// value is still within maxStale, that value is returned instead and a
+// background revalidation is scheduled, at most one per key at a time.
+func (c *Cache[T]) Do(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (Result[T], error) {
+	if value, age, ok := c.get(key); ok && age <= c.ttl {
+		return Result[T]{Value: value, Age: age, Hit: true}, nil
+	}
+
+	value, err := c.load(ctx, key, load)
+	if err == nil {
+		return Result[T]{Value: value}, nil
+	}
+
+	stale, age, ok := c.get(key)
+	if !ok {
+		return Result[T]{}, err
+	}
+
+	c.mu.Lock()
+	if !c.revalidating[key] {
+		c.revalidating[key] = true
+		go c.revalidate(key, load)
+	}
+	c.mu.Unlock()
+
+	return Result[T]{Value: stale, Age: age, Hit: true, Stale: true}, nil
+}
+
+// load joins the load in flight for key in this process, starting one if
+// there is none, and waits for it or for ctx to be done
+func (c *Cache[T]) load(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	current, ok := c.calls[key]
+	if !ok {
+		// The load outlives any one caller, so it only inherits their values
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		current = &call[T]{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = current
+		go c.run(loadCtx, key, current, load)
+	}
+	current.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.value, current.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		current.waiters--
+		if current.waiters == 0 {
+			current.cancel()
+			if c.calls[key] == current {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// run performs a shared load and stores its value
+func (c *Cache[T]) run(ctx context.Context, key string, current *call[T], load func(ctx context.Context) (T, error)) {
+	defer current.cancel()
+
+	current.value, current.err = load(ctx)
+	if current.err == nil {
+		c.Set(key, current.value)
+	}
+
+	c.mu.Lock()
+	if c.calls[key] == current {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(current.done)
+}
+
+// revalidate retries a failed load after RevalidateAfter unless the entry was
+// refreshed in the meantime
+func (c *Cache[T]) revalidate(key string, load func(ctx context.Context) (T, error)) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.revalidating, key)
+		c.mu.Unlock()
+	}()
+
+	time.Sleep(RevalidateAfter)
+
+	if _, _, fresh := c.Get(key); fresh {
+		return
+	}
+	c.load(context.Background(), key, load)
+}
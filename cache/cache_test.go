@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoServesStaleAndRevalidatesOncePerKey(t *testing.T) {
+	previous := RevalidateAfter
+	RevalidateAfter = 50 * time.Millisecond
+	t.Cleanup(func() { RevalidateAfter = previous })
+
+	c := New[string](NewMemory(0), time.Millisecond, time.Hour)
+	c.Set("key", "cached")
+	time.Sleep(5 * time.Millisecond)
+
+	var loads atomic.Int32
+	failing := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		return "", errors.New("upstream down")
+	}
+
+	const requests = 5
+	for range requests {
+		result, err := c.Do(context.Background(), "key", failing)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		if !result.Stale || result.Value != "cached" {
+			t.Fatalf("Do = %+v, want the stale cached value", result)
+		}
+	}
+
+	time.Sleep(4 * RevalidateAfter)
+	if got := loads.Load(); got != requests+1 {
+		t.Errorf("got %d loads, want %d for the requests plus one revalidation", got, requests+1)
+	}
+}
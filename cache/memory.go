@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Backend holding up to capacity entries, evicting
+// the least recently used
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type memoryItem struct {
+	key       string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// NewMemory creates a Memory backend. A capacity of zero means unbounded.
+func NewMemory(capacity int) *Memory {
+	return &Memory{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the entry stored under key, dropping it if it has expired
+func (m *Memory) Get(key string) (Entry, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.items[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+
+	item := element.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		m.order.Remove(element)
+		delete(m.items, key)
+		return Entry{}, false, nil
+	}
+
+	m.order.MoveToFront(element)
+	return item.entry, true, nil
+}
+
+// Set stores entry under key, evicting the least recently used entry when full
+func (m *Memory) Set(key string, entry Entry, keep time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := &memoryItem{key: key, entry: entry, expiresAt: time.Now().Add(keep)}
+	if element, ok := m.items[key]; ok {
+		element.Value = item
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(item)
+	for m.capacity > 0 && m.order.Len() > m.capacity {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.items, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	m := NewMemory(2)
+	m.Set("a", Entry{Value: []byte(`"a"`)}, time.Hour)
+	m.Set("b", Entry{Value: []byte(`"b"`)}, time.Hour)
+
+	// Reading a makes b the least recently used
+	if _, ok, _ := m.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	m.Set("c", Entry{Value: []byte(`"c"`)}, time.Hour)
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := m.Get(key); ok != want {
+			t.Errorf("Get(%s) present = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestMemoryOverwriteDoesNotEvict(t *testing.T) {
+	m := NewMemory(2)
+	m.Set("a", Entry{Value: []byte(`1`)}, time.Hour)
+	m.Set("b", Entry{Value: []byte(`1`)}, time.Hour)
+	m.Set("a", Entry{Value: []byte(`2`)}, time.Hour)
+
+	entry, ok, _ := m.Get("a")
+	if !ok || string(entry.Value) != "2" {
+		t.Errorf("Get(a) = %s, %v, want the overwritten value", entry.Value, ok)
+	}
+	if _, ok, _ := m.Get("b"); !ok {
+		t.Error("b was evicted by overwriting a")
+	}
+}
+
+func TestMemoryExpires(t *testing.T) {
+	m := NewMemory(0)
+	m.Set("a", Entry{Value: []byte(`"a"`)}, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := m.Get("a"); ok {
+		t.Error("expired entry was returned")
+	}
+	if len(m.items) != 0 || m.order.Len() != 0 {
+		t.Error("expired entry was not dropped")
+	}
+}
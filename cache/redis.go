@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each Redis call so a slow Redis degrades to cache misses
+const redisTimeout = 2 * time.Second
+
+// Redis is a Backend shared between processes through a Redis server.
+// Entries expire through Redis key TTLs rather than a capacity.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis creates a Redis backend that namespaces its keys with prefix
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+// RedisFactory is a Factory giving each cache a Redis backend on client,
+// with keys namespaced by the cache name
+func RedisFactory(client redis.UniversalClient, prefix string) Factory {
+	return func(name string, capacity int) Backend {
+		return NewRedis(client, prefix+name+":")
+	}
+}
+
+// Get returns the entry stored under key
+func (r *Redis) Get(key string) (Entry, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("failed to read cache key %s: %w", key, err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false, fmt.Errorf("failed to decode cache key %s: %w", key, err)
+	}
+	return entry, true, nil
+}
+
+// Set stores entry under key with a Redis TTL of keep
+func (r *Redis) Set(key string, entry Entry, keep time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Set(ctx, r.prefix+key, data, keep).Err(); err != nil {
+		return fmt.Errorf("failed to write cache key %s: %w", key, err)
+	}
+	return nil
+}
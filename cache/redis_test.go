@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestRedis starts an in-process Redis server and returns a client for it
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRedisRoundTrip(t *testing.T) {
+	server, client := newTestRedis(t)
+	backend := NewRedis(client, "test:")
+
+	stored := Entry{Value: []byte(`{"title":"Tito's"}`), StoredAt: time.Now().Truncate(time.Second)}
+	if err := backend.Set("search:titos", stored, time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if !server.Exists("test:search:titos") {
+		t.Fatal("entry was not written under the prefixed key")
+	}
+
+	entry, ok, err := backend.Get("search:titos")
+	if err != nil || !ok {
+		t.Fatalf("Get = %v, %v", ok, err)
+	}
+	if string(entry.Value) != string(stored.Value) || !entry.StoredAt.Equal(stored.StoredAt) {
+		t.Errorf("Get = %+v, want %+v", entry, stored)
+	}
+
+	// Entries expire through the Redis key TTL
+	server.FastForward(2 * time.Minute)
+	if _, ok, err := backend.Get("search:titos"); ok || err != nil {
+		t.Errorf("Get after expiry = %v, %v, want a miss", ok, err)
+	}
+}
+
+func TestRedisFactoryNamespacesCaches(t *testing.T) {
+	server, client := newTestRedis(t)
+	factory := RedisFactory(client, "abc:")
+
+	search := New[string](factory("search", 10), time.Minute, 0)
+	stores := New[string](factory("stores", 10), time.Minute, 0)
+	search.Set("23220", "search value")
+	stores.Set("23220", "stores value")
+
+	if !server.Exists("abc:search:23220") || !server.Exists("abc:stores:23220") {
+		t.Fatalf("keys = %v, want one per cache name", server.Keys())
+	}
+	if value, _, ok := search.Get("23220"); !ok || value != "search value" {
+		t.Errorf("search.Get = %q, %v", value, ok)
+	}
+
+	// A second replica on the same Redis sees the first one's entries
+	replica := New[string](RedisFactory(client, "abc:")("stores", 10), time.Minute, 0)
+	result, err := replica.Do(context.Background(), "23220", func(ctx context.Context) (string, error) {
+		t.Error("replica loaded a value another replica had cached")
+		return "", nil
+	})
+	if err != nil || !result.Hit || result.Value != "stores value" {
+		t.Errorf("replica.Do = %+v, %v", result, err)
+	}
+}
+
+func TestRedisFailureIsAnError(t *testing.T) {
+	server, client := newTestRedis(t)
+	backend := NewRedis(client, "test:")
+	server.Close()
+
+	if _, ok, err := backend.Get("key"); ok || err == nil {
+		t.Errorf("Get on a closed server = %v, %v, want an error", ok, err)
+	}
+
+	// The cache treats backend failures as misses rather than failing the request
+	c := New[string](backend, time.Minute, 0)
+	result, err := c.Do(context.Background(), "key", func(ctx context.Context) (string, error) {
+		return "loaded", nil
+	})
+	if err != nil || result.Value != "loaded" {
+		t.Errorf("Do = %+v, %v, want the loaded value", result, err)
+	}
+}
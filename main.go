@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"ABCScraper/alerts"
 	"ABCScraper/api"
+	"ABCScraper/browser"
+	"ABCScraper/cache"
+	"ABCScraper/scrapers"
+	"ABCScraper/snapshot"
+	"ABCScraper/storage"
 
 	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/cors"
 )
 
@@ -19,16 +30,156 @@ func main() {
 		port = "8080"
 	}
 
+	// How long a synced store directory answers nearest-store queries
+	scrapers.StoreDirectoryMaxAge = envDuration("STORE_DIRECTORY_MAX_AGE", scrapers.StoreDirectoryMaxAge)
+
+	// Allow pointing the inventory scraper at a local fake inventory server
+	if inventoryURL := os.Getenv("ABC_INVENTORY_URL"); inventoryURL != "" {
+		scrapers.InventoryBaseURL = inventoryURL
+	}
+
+	// Optionally pass Cloudflare challenges in a headless browser and retry
+	scrapers.CloudflareHandoff = os.Getenv("CLOUDFLARE_HANDOFF") == "true"
+	scrapers.CloudflareSolveTimeout = envDuration("CLOUDFLARE_SOLVE_TIMEOUT", scrapers.CloudflareSolveTimeout)
+
+	// Fall back to scraping the rendered site when the Coveo API path fails
+	scrapers.BrowserFallback = os.Getenv("BROWSER_FALLBACK") == "true"
+	if browserURL := os.Getenv("ABC_BROWSER_URL"); browserURL != "" {
+		scrapers.BrowserBaseURL = browserURL
+	}
+
+	// Pool of headless browsers shared by the Cloudflare handoff and the browser fallback
+	scrapers.ConfigureBrowserPool(browser.Config{
+		Size:         envInt("BROWSER_POOL_SIZE", browser.DefaultConfig.Size),
+		Headful:      os.Getenv("BROWSER_HEADLESS") == "false",
+		TaskTimeout:  envDuration("BROWSER_TIMEOUT", browser.DefaultConfig.TaskTimeout),
+		MaxTasks:     envInt("BROWSER_MAX_TASKS", browser.DefaultConfig.MaxTasks),
+		MaxAge:       envDuration("BROWSER_MAX_AGE", browser.DefaultConfig.MaxAge),
+		MaxHeapBytes: float64(envInt("BROWSER_MAX_HEAP_MB", 512)) * (1 << 20),
+		ExecPath:     os.Getenv("CHROME_PATH"),
+	})
+	defer scrapers.CloseBrowserPool()
+
+	// Open the catalog database and persist every scrape into it
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = "abcscraper.db"
+	}
+	storage.MaxRuns = envInt("RUN_RETENTION", storage.MaxRuns)
+	storage.ChangeRetention = envDuration("CHANGE_RETENTION", storage.ChangeRetention)
+	repo, err := storage.Open(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open catalog storage: %v", err)
+	}
+	defer repo.Close()
+	scrapers.SetRecorder(repo)
+
+	// Offline catalog snapshots, rebuilt after each crawl
+	snapshotDir := os.Getenv("SNAPSHOT_DIR")
+	if snapshotDir == "" {
+		snapshotDir = "snapshots"
+	}
+	snapshots, err := snapshot.NewStore(snapshotDir, envInt("SNAPSHOT_KEEP", 5), envDuration("SNAPSHOT_MAX_AGE", 30*24*time.Hour))
+	if err != nil {
+		log.Fatalf("Failed to open snapshot store: %v", err)
+	}
+
+	// Response and geocode cache sizes and TTLs, and how long past the TTL a
+	// cached response may be served while the upstream is failing
+	config := api.DefaultConfig
+	config.ProductSearchCacheTTL = envDuration("SEARCH_CACHE_TTL", config.ProductSearchCacheTTL)
+	config.StoreSearchCacheTTL = envDuration("STORE_CACHE_TTL", config.StoreSearchCacheTTL)
+	config.ProductSearchMaxStale = envDuration("SEARCH_MAX_STALE", config.ProductSearchMaxStale)
+	config.StoreSearchMaxStale = envDuration("STORE_MAX_STALE", config.StoreSearchMaxStale)
+	config.SalesCacheTTL = envDuration("SALES_CACHE_TTL", config.SalesCacheTTL)
+	config.SalesMaxStale = envDuration("SALES_MAX_STALE", config.SalesMaxStale)
+	cache.RevalidateAfter = envDuration("STALE_REVALIDATE_AFTER", cache.RevalidateAfter)
+	config.ResponseCacheSize = envInt("RESPONSE_CACHE_SIZE", config.ResponseCacheSize)
+
+	// Per-endpoint request deadlines
+	config.StoreSearchTimeout = envDuration("STORE_SEARCH_TIMEOUT", config.StoreSearchTimeout)
+	config.ProductSearchTimeout = envDuration("SEARCH_TIMEOUT", config.ProductSearchTimeout)
+	config.AvailabilityTimeout = envDuration("AVAILABILITY_TIMEOUT", config.AvailabilityTimeout)
+	config.ShoppingListTimeout = envDuration("SHOPPING_LIST_TIMEOUT", config.ShoppingListTimeout)
+	config.SalesTimeout = envDuration("SALES_TIMEOUT", config.SalesTimeout)
+
+	// Share caches and the bearer token between replicas through Redis when configured
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_URL: %v", err)
+		}
+		redisClient := redis.NewClient(options)
+		defer redisClient.Close()
+
+		config.CacheFactory = cache.RedisFactory(redisClient, "abcscraper:")
+		fmt.Println("Sharing caches through Redis at", options.Addr)
+	}
+	scrapers.ConfigureGeocodeCache(config.CacheFactory, envInt("GEOCODE_CACHE_SIZE", 10000), envDuration("GEOCODE_CACHE_TTL", 30*24*time.Hour))
+	scrapers.ConfigureTokenCache(config.CacheFactory)
+	scrapers.TokenTTL = envDuration("TOKEN_TTL", scrapers.TokenTTL)
+
+	// Long-lived client every upstream request goes through, optionally via a proxy
+	if err := scrapers.ConfigureUpstreamClient(scrapers.ClientConfig{
+		Timeout:             envDuration("UPSTREAM_TIMEOUT", scrapers.DefaultClientConfig.Timeout),
+		Proxy:               os.Getenv("UPSTREAM_PROXY"),
+		MaxIdleConns:        envInt("UPSTREAM_MAX_IDLE_CONNS", scrapers.DefaultClientConfig.MaxIdleConns),
+		MaxIdleConnsPerHost: envInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", scrapers.DefaultClientConfig.MaxIdleConnsPerHost),
+		IdleConnTimeout:     envDuration("UPSTREAM_IDLE_CONN_TIMEOUT", scrapers.DefaultClientConfig.IdleConnTimeout),
+		TLSHandshakeTimeout: envDuration("UPSTREAM_TLS_HANDSHAKE_TIMEOUT", scrapers.DefaultClientConfig.TLSHandshakeTimeout),
+		RetryCount:          envInt("UPSTREAM_RETRIES", scrapers.DefaultClientConfig.RetryCount),
+		RetryWait:           envDuration("UPSTREAM_RETRY_WAIT", scrapers.DefaultClientConfig.RetryWait),
+		RetryMaxWait:        envDuration("UPSTREAM_RETRY_MAX_WAIT", scrapers.DefaultClientConfig.RetryMaxWait),
+	}); err != nil {
+		log.Fatalf("Invalid upstream client settings: %v", err)
+	}
+
+	// Rotate requests to abc.virginia.gov through a pool of HTTP or SOCKS5 proxies
+	if proxyURLs := os.Getenv("UPSTREAM_PROXIES"); proxyURLs != "" {
+		if err := scrapers.ConfigureProxyPool(scrapers.ProxyPoolConfig{
+			URLs:                strings.Split(proxyURLs, ","),
+			HealthCheckURL:      os.Getenv("PROXY_HEALTH_CHECK_URL"),
+			HealthCheckInterval: envDuration("PROXY_HEALTH_CHECK_INTERVAL", scrapers.DefaultProxyPoolConfig.HealthCheckInterval),
+			MaxBlocks:           envInt("PROXY_MAX_BLOCKS", scrapers.DefaultProxyPoolConfig.MaxBlocks),
+			BlockCooldown:       envDuration("PROXY_BLOCK_COOLDOWN", scrapers.DefaultProxyPoolConfig.BlockCooldown),
+		}); err != nil {
+			log.Fatalf("Invalid UPSTREAM_PROXIES: %v", err)
+		}
+	}
+
+	// Shared limit on requests to abc.virginia.gov. Interactive requests that
+	// would wait longer than UPSTREAM_MAX_WAIT get a 503 instead.
+	scrapers.ConfigureUpstreamLimit(
+		envFloat("UPSTREAM_RATE", 5),
+		envInt("UPSTREAM_BURST", 10),
+		envInt("UPSTREAM_MAX_IN_FLIGHT", 4),
+		envDuration("UPSTREAM_MAX_WAIT", 5*time.Second),
+		envDuration("UPSTREAM_BACKGROUND_MAX_WAIT", 10*time.Minute),
+	)
+
+	// Fail fast on upstream dependencies that keep failing
+	scrapers.ConfigureCircuitBreakers(envInt("CIRCUIT_FAILURE_THRESHOLD", 5), envDuration("CIRCUIT_OPEN_DURATION", 30*time.Second))
+
+	// Crawl the full catalog in the background
+	go crawlCatalog(envDuration("CATALOG_CRAWL_INTERVAL", 24*time.Hour), snapshots)
+
+	// Re-check watched SKUs and send webhook alerts
+	go alerts.NewEvaluator(repo, envDuration("WATCHLIST_INTERVAL", time.Hour)).Run(context.Background())
+
+	// Keep the local store directory synced for nearest-store queries
+	go syncStoreDirectory(envDuration("STORE_SYNC_INTERVAL", 12*time.Hour))
+
 	// Create main router
 	r := mux.NewRouter()
 
-	// Setup API routes
-	api.SetupRoutes(r)
+	// Setup API routes, served by the live scrapers
+	server := api.NewServer(config, api.Backends{}, repo, snapshots, nil)
+	server.SetupRoutes(r)
 
 	// Setup CORS for production
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"}, // In production, specify your React Native app's domains
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
 		AllowCredentials: false,
 	})
@@ -39,8 +190,106 @@ func main() {
 	fmt.Printf(" API running on port %s\n", port)
 	fmt.Println(" Available endpoints:")
 	fmt.Println("   GET  /health")
+	fmt.Println("   GET  /metrics")
+	fmt.Println("   GET  /api/v1/stores")
 	fmt.Println("   GET  /api/v1/stores/{zipcode}")
 	fmt.Println("   GET  /api/v1/productsearch/{query}")
+	fmt.Println("   GET  /api/v1/products/{sku}/availability/{zipcode}")
+	fmt.Println("   GET  /api/v1/products/{id}/history")
+	fmt.Println("   POST /api/v1/shoppinglist")
+	fmt.Println("   GET  /api/v1/catalog/products")
+	fmt.Println("   GET  /api/v1/catalog/products/{key}")
+	fmt.Println("   GET  /api/v1/catalog/skus/{sku}")
+	fmt.Println("   GET  /api/v1/catalog/snapshot")
+	fmt.Println("   GET  /api/v1/scraperuns")
+	fmt.Println("   GET  /api/v1/watchlist")
+	fmt.Println("   POST /api/v1/watchlist")
+	fmt.Println("   GET  /api/v1/watchlist/{id}")
+	fmt.Println("   PUT  /api/v1/watchlist/{id}")
+	fmt.Println("   DELETE /api/v1/watchlist/{id}")
+	fmt.Println("   GET  /api/v1/watchlist/deadletters")
+	fmt.Println("   GET  /api/v1/sales")
+	fmt.Println("   GET  /api/v1/changes")
+	fmt.Println("   GET  /api/v1/feed/releases")
 
 	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
+
+// syncStoreDirectory syncs the store directory on startup and then on every interval
+func syncStoreDirectory(interval time.Duration) {
+	for {
+		count, err := scrapers.SyncStoreDirectory(context.Background())
+		if err != nil {
+			log.Printf("Store directory sync failed: %v", err)
+		} else {
+			log.Printf("Store directory synced with %d stores", count)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// crawlCatalog crawls the full product catalog on startup and then on every interval,
+// rebuilding the offline snapshot after each crawl. Results reach storage through
+// the scrapers' recorder.
+func crawlCatalog(interval time.Duration, snapshots *snapshot.Store) {
+	for {
+		products, err := scrapers.CrawlCatalog(context.Background())
+		if err != nil {
+			log.Printf("Catalog crawl failed: %v", err)
+		} else {
+			log.Printf("Catalog crawl stored %d products", len(products))
+
+			if info, err := snapshots.Build(products); err != nil {
+				log.Printf("Catalog snapshot failed: %v", err)
+			} else {
+				log.Printf("Catalog snapshot version %d ready", info.Version)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// envInt reads an integer from an environment variable, falling back to def
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", name, value, def)
+		return def
+	}
+	return n
+}
+
+// envFloat reads a number such as "2.5" from an environment variable, falling back to def
+func envFloat(name string, def float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		log.Printf("Invalid %s %q, using %g", name, value, def)
+		return def
+	}
+	return f
+}
+
+// envDuration reads a duration such as "12h" from an environment variable, falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", name, value, def)
+		return def
+	}
+	return d
+}
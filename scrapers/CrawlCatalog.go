@@ -0,0 +1,117 @@
+package scrapers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// catalogPageSize is how many products are requested per Coveo page while crawling
+	catalogPageSize = 100
+
+	// catalogMaxResults is the deepest Coveo lets a query page
+	catalogMaxResults = 5000
+)
+
+// ErrCatalogTruncated means a crawl matched more products than Coveo lets a
+// single query page through, so it could not return them all
+var ErrCatalogTruncated = fmt.Errorf("catalog crawl would be truncated: %w", ErrUpstreamFailed)
+
+// CrawlCatalog pages through every product in the Coveo index, not just the
+// ones matching a search, so the whole catalog can be stored and compared
+func CrawlCatalog(ctx context.Context) ([]ProductResult, error) {
+	run := newRun(RunCatalogCrawl, "")
+	products, err := crawlProducts(ctx, catalogQuery(), PriorityBackground)
+	recordProducts(run, products, err)
+
+	return products, err
+}
+
+// catalogQuery returns the Coveo form fields that select every non-duplicate product
+func catalogQuery() url.Values {
+	form := url.Values{}
+	form.Set("aq", "(NOT (@z95xproductz32xlabelz32xduplicate == 'True')) (@z95xresultz32xtype==Product)")
+	form.Set("cq", `(@z95xlanguage==en) (@z95xlatestversion==1) (@source=="Coveo_web_index - KubProd2")`)
+	form.Set("searchHub", "Search-Results")
+	form.Set("locale", "en")
+	form.Set("maximumAge", "900000")
+	form.Set("sortCriteria", "@systitle ascending")
+	form.Set("timezone", "America/New_York")
+	form.Set("enableDidYouMean", "false")
+	form.Set("enableQuerySyntax", "false")
+	form.Set("enableDuplicateFiltering", "false")
+	form.Set("debug", "false")
+	form.Set("allowQueriesWithoutKeywords", "true")
+	return form
+}
+
+// crawlProducts pages through every product matching a Coveo query, waiting
+// for the upstream limiter at the given priority before each page
+func crawlProducts(ctx context.Context, query url.Values, priority Priority) ([]ProductResult, error) {
+	token, err := readToken()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := productSearchHeaders(token)
+
+	var products []ProductResult
+	for firstResult := 0; firstResult < catalogMaxResults; firstResult += catalogPageSize {
+		query.Set("firstResult", strconv.Itoa(firstResult))
+		query.Set("numberOfResults", strconv.Itoa(catalogPageSize))
+
+		// The first page loads with the results page, later ones are paged to
+		cause := causePagerNext
+		if firstResult == 0 {
+			cause = causeInterfaceLoad
+		}
+
+		resp, err := callABC(ctx, coveoBreaker, priority, resty.MethodPost, productSearchURL, func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+			visitor.search(query, searchResultsPage, "", cause)
+
+			return client.R().
+				SetHeaders(headers).
+				SetBody(query.Encode())
+		})
+
+		if err != nil {
+			return nil, requestError("coveo", err)
+		}
+
+		if resp.StatusCode() != 200 {
+			return nil, statusError("coveo", resp)
+		}
+
+		// parseProductResults drops results without a product id, so use
+		// Coveo's total count rather than the page length to find the end
+		var meta struct {
+			TotalCount int `json:"totalCount"`
+		}
+		if err := json.Unmarshal(resp.Body(), &meta); err != nil {
+			return nil, schemaError("coveo", err)
+		}
+
+		// A partial catalog would look like removed products to the change
+		// log and snapshots, so fail instead of stopping at the paging limit
+		if meta.TotalCount > catalogMaxResults {
+			return nil, fmt.Errorf("coveo matched %d products, more than the %d a query can page through: %w", meta.TotalCount, catalogMaxResults, ErrCatalogTruncated)
+		}
+
+		page, err := parseProductResults(resp.Body())
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, page...)
+
+		if firstResult+catalogPageSize >= meta.TotalCount {
+			break
+		}
+	}
+
+	return products, nil
+}
@@ -0,0 +1,154 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserFallback makes product searches fall back to scraping the rendered
+// search results page when the Coveo API path fails
+var BrowserFallback = false
+
+// browserResultSelector matches one product card in a rendered Coveo result list
+const browserResultSelector = ".CoveoResult"
+
+// browserProductScript reads every product card on the page. Each field tries
+// the markup of the product card first and falls back to looser selectors.
+const browserProductScript = `Array.from(document.querySelectorAll('.CoveoResult')).map(card => {
+	const text = selector => { const el = card.querySelector(selector); return el ? el.textContent.trim() : ''; };
+	const all = selector => Array.from(card.querySelectorAll(selector)).map(el => el.textContent.trim()).filter(t => t.length > 0);
+	const link = card.querySelector('a.CoveoResultLink, .product-header a, a[href]');
+	const image = card.querySelector('img');
+	return {
+		title: text('.product-header h4') || text('h4'),
+		url: link ? link.href : '',
+		image: image ? (image.currentSrc || image.src) : '',
+		category: text('.product-category'),
+		sizes: all('.product-size, .size'),
+		prices: all('.product-price, .price'),
+		skus: Array.from(card.querySelectorAll('[data-product-code], [data-sku]')).map(el => el.dataset.productCode || el.dataset.sku),
+	};
+}).filter(product => product.title.length > 0)`
+
+// browserProduct is one product card as read by browserProductScript
+type browserProduct struct {
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Image    string   `json:"image"`
+	Category string   `json:"category"`
+	Sizes    []string `json:"sizes"`
+	Prices   []string `json:"prices"`
+	SKUs     []string `json:"skus"`
+}
+
+// productCodePattern finds the six digit product code at the end of a product link
+var productCodePattern = regexp.MustCompile(`(\d{6})/?$`)
+
+// pricePattern pulls the amount out of price text such as "Price: $22.99"
+var pricePattern = regexp.MustCompile(`\$?\d+(?:\.\d{2})?`)
+
+// BrowserProductsSearch searches the catalog by rendering the site's search
+// results page in a headless browser
+func BrowserProductsSearch(ctx context.Context, query string) ([]ProductResult, error) {
+	return browserProductsSearch(ctx, query, PriorityInteractive)
+}
+
+// browserProductsSearch is BrowserProductsSearch at the given upstream priority
+func browserProductsSearch(ctx context.Context, query string, priority Priority) ([]ProductResult, error) {
+	return scrapeProductsBrowser(ctx, BrowserBaseURL+"/search-results#q="+url.PathEscape(query), priority)
+}
+
+// ScrapeProductsBrowser loads a product listing page in a headless browser,
+// waits for the Coveo result cards to render and reads the products from the DOM
+func ScrapeProductsBrowser(ctx context.Context, pageURL string) ([]ProductResult, error) {
+	return scrapeProductsBrowser(ctx, pageURL, PriorityInteractive)
+}
+
+// scrapeProductsBrowser is ScrapeProductsBrowser at the given upstream
+// priority. The page load counts as one request against the upstream limiter.
+func scrapeProductsBrowser(ctx context.Context, pageURL string, priority Priority) ([]ProductResult, error) {
+	release, err := acquireUpstream(ctx, priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var cards []browserProduct
+	err = runBrowser(ctx, 0,
+		chromedp.Navigate(pageURL),
+		waitForChallenge(),
+
+		// Coveo renders results client side, so wait for the cards to appear
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			for {
+				var nodes []*cdp.Node
+				if err := chromedp.Nodes(browserResultSelector, &nodes, chromedp.ByQueryAll, chromedp.AtLeast(0)).Do(ctx); err != nil {
+					return err
+				}
+				if len(nodes) > 0 {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(500 * time.Millisecond):
+				}
+			}
+		}),
+
+		chromedp.Evaluate(browserProductScript, &cards),
+	)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("browser scrape of %s timed out: %w", pageURL, ErrUpstreamTimeout)
+		}
+		return nil, fmt.Errorf("browser scrape of %s failed: %w", pageURL, err)
+	}
+
+	products := make([]ProductResult, 0, len(cards))
+	for _, card := range cards {
+		products = append(products, card.product())
+	}
+
+	return products, nil
+}
+
+// product converts a product card into the same shape the Coveo API path returns
+func (c browserProduct) product() ProductResult {
+	var prices []string
+	for _, price := range c.Prices {
+		prices = append(prices, strings.TrimPrefix(pricePattern.FindString(price), "$"))
+	}
+
+	productID := ""
+	if match := productCodePattern.FindStringSubmatch(c.URL); match != nil {
+		productID = match[1]
+	}
+
+	// Cards without per-size codes only identify the product itself
+	skus := c.SKUs
+	if len(skus) == 0 && productID != "" {
+		skus = []string{productID}
+	}
+
+	return ProductResult{
+		Title:      c.Title,
+		ProductID:  productID,
+		Sizes:      strings.Join(c.Sizes, ", "),
+		SizesID:    strings.Join(skus, ", "),
+		SizesPrice: strings.Join(prices, ", "),
+		Image:      c.Image,
+		URL:        c.URL,
+		Category:   c.Category,
+		Variants:   buildVariants(skus, c.Sizes, prices, nil),
+	}
+}
@@ -0,0 +1,140 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	"ABCScraper/browser"
+)
+
+func TestBrowserProductConversion(t *testing.T) {
+	tests := []struct {
+		name string
+		card browserProduct
+		want ProductResult
+	}{
+		{
+			name: "per-size product codes",
+			card: browserProduct{
+				Title:    "Tito's Handmade Vodka",
+				URL:      "https://www.abc.virginia.gov/products/vodka/titos-handmade-vodka-010807",
+				Image:    "https://www.abc.virginia.gov/-/media/products/010807.png",
+				Category: "Vodka",
+				Sizes:    []string{"750 mL", "1.75 L"},
+				Prices:   []string{"Price: $22.99", "Price: $36.99"},
+				SKUs:     []string{"010807", "010806"},
+			},
+			want: ProductResult{
+				Title:      "Tito's Handmade Vodka",
+				ProductID:  "010807",
+				Sizes:      "750 mL, 1.75 L",
+				SizesID:    "010807, 010806",
+				SizesPrice: "22.99, 36.99",
+				Image:      "https://www.abc.virginia.gov/-/media/products/010807.png",
+				URL:        "https://www.abc.virginia.gov/products/vodka/titos-handmade-vodka-010807",
+				Category:   "Vodka",
+				Variants: []ProductVariant{
+					{SKU: "010807", Size: "750 mL", Price: 22.99},
+					{SKU: "010806", Size: "1.75 L", Price: 36.99},
+				},
+			},
+		},
+		{
+			name: "product code only in the link",
+			card: browserProduct{
+				Title:  "Buffalo Trace Bourbon",
+				URL:    "https://www.abc.virginia.gov/products/bourbon/buffalo-trace-bourbon-018006/",
+				Sizes:  []string{"750 mL"},
+				Prices: []string{"$29.99"},
+			},
+			want: ProductResult{
+				Title:      "Buffalo Trace Bourbon",
+				ProductID:  "018006",
+				Sizes:      "750 mL",
+				SizesID:    "018006",
+				SizesPrice: "29.99",
+				URL:        "https://www.abc.virginia.gov/products/bourbon/buffalo-trace-bourbon-018006/",
+				Variants:   []ProductVariant{{SKU: "018006", Size: "750 mL", Price: 29.99}},
+			},
+		},
+		{
+			name: "no product code",
+			card: browserProduct{Title: "Gift Card", URL: "https://www.abc.virginia.gov/gift-cards"},
+			want: ProductResult{
+				Title:    "Gift Card",
+				URL:      "https://www.abc.virginia.gov/gift-cards",
+				Variants: []ProductVariant{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.card.product(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("product() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// chromePath finds a Chrome or Chromium binary to run the browser tests with,
+// from CHROME_PATH or the PATH
+func chromePath() string {
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		return path
+	}
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "headless-shell"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func TestScrapeProductsBrowserReadsFixture(t *testing.T) {
+	execPath := chromePath()
+	if execPath == "" {
+		t.Skip("no Chrome or Chromium found; set CHROME_PATH to run the browser tests")
+	}
+
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	config := browser.DefaultConfig
+	config.ExecPath = execPath
+	ConfigureBrowserPool(config)
+	t.Cleanup(func() { ConfigureBrowserPool(browser.DefaultConfig) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	products, err := ScrapeProductsBrowser(ctx, server.URL+"/coveo_results.html")
+	if err != nil {
+		t.Fatalf("ScrapeProductsBrowser: %v", err)
+	}
+
+	want := []struct {
+		title, productID, sizesID, prices string
+	}{
+		{"Tito's Handmade Vodka", "010807", "010807, 010806", "22.99, 36.99"},
+		{"Buffalo Trace Bourbon", "018006", "018006", "29.99"},
+	}
+	if len(products) != len(want) {
+		t.Fatalf("got %d products, want %d: %+v", len(products), len(want), products)
+	}
+	for i, product := range products {
+		if product.Title != want[i].title || product.ProductID != want[i].productID ||
+			product.SizesID != want[i].sizesID || product.SizesPrice != want[i].prices {
+			t.Errorf("product %d = %+v, want %+v", i, product, want[i])
+		}
+		if product.Image != server.URL+"/-/media/products/"+want[i].productID+".png" {
+			t.Errorf("product %d image = %q, want it resolved against the page", i, product.Image)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package scrapers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,41 +15,42 @@ import (
 )
 
 type ProductResult struct {
-	Title      string  `json:"title"`
-	ProductID  string  `json:"productid"`
-	Sizes      string  `json:"sizes"`
-	SizesID    string  `json:"sizesID"`
-	SizesPrice string  `json:"sizesprice"`
-	ABV        float64 `json:"abv"`
-	Image      string  `json:"image"`
+	Title               string           `json:"title"`
+	ProductID           string           `json:"productid"`
+	Sizes               string           `json:"sizes"`
+	SizesID             string           `json:"sizesID"`
+	SizesPrice          string           `json:"sizesprice"`
+	ABV                 float64          `json:"abv"`
+	Image               string           `json:"image"`
+	URL                 string           `json:"url"`
+	Category            string           `json:"category"`
+	OnSale              bool             `json:"on_sale"`
+	NewProduct          bool             `json:"new_product"`
+	LimitedAvailability bool             `json:"limited_availability"`
+	Lottery             bool             `json:"lottery"`
+	StoreInventory      bool             `json:"store_inventory"`
+	WarehouseInventory  bool             `json:"warehouse_inventory"`
+	SaleStart           *time.Time       `json:"sale_start,omitempty"`
+	SaleEnd             *time.Time       `json:"sale_end,omitempty"`
+	Variants            []ProductVariant `json:"variants"`
 }
 
-func ScrapeProductsSearch(query string) ([]ProductResult, error) {
+// ProductVariant is one bottle size of a product with its own SKU and price
+type ProductVariant struct {
+	SKU   string  `json:"sku"`
+	Size  string  `json:"size"`
+	Price float64 `json:"price"`
 
-	tokenBytes, err := os.ReadFile("uptodatetoken.txt")
-	if err != nil {
-		return nil, fmt.Errorf("failed to read token from uptodatetoken.txt: %w", err)
-	}
-
-	// Split by newlines and take only the first line (the token)
-	lines := strings.Split(string(tokenBytes), "\n")
-	if len(lines) == 0 {
-		return nil, fmt.Errorf("token file uptodatetoken.txt is empty")
-	}
-
-	token := strings.TrimSpace(lines[0])
-	if token == "" {
-		return nil, fmt.Errorf("token file uptodatetoken.txt is empty")
-	}
-
-	client := resty.New()
-
-	// Set timeout for the request
-	client.SetTimeout(30 * time.Second)
+	// The non-sale price, when Coveo reports one for a product on sale
+	RegularPrice float64 `json:"regular_price,omitempty"`
+}
 
-	apiURL := "https://www.abc.virginia.gov/coveo/rest/search/v2?sitecoreItemUri=sitecore%3A%2F%2Fweb%2F%7B514C7796-41D8-497D-AA53-FE33B3716B88%7D%3Flang%3Den%26amp%3Bver%3D2&siteName=website"
+// productSearchURL is the Coveo search endpoint used by the ABC search results page
+const productSearchURL = "https://www.abc.virginia.gov/coveo/rest/search/v2?sitecoreItemUri=sitecore%3A%2F%2Fweb%2F%7B514C7796-41D8-497D-AA53-FE33B3716B88%7D%3Flang%3Den%26amp%3Bver%3D2&siteName=website"
 
-	headers := map[string]string{
+// productSearchHeaders returns the browser-like headers sent with product searches
+func productSearchHeaders(token string) map[string]string {
+	return map[string]string{
 		"Host":                        "www.abc.virginia.gov",
 		"Sec-Ch-Ua-Full-Version-List": "",
 		"Sec-Ch-Ua-Platform":          "\"Windows\"",
@@ -68,49 +73,125 @@ func ScrapeProductsSearch(query string) ([]ProductResult, error) {
 		"Referer":                     "https://www.abc.virginia.gov/search-results",
 		"Accept-Encoding":             "gzip, deflate, br",
 		"Priority":                    "u=1, i",
-		"Cookie":                      "__cf_bm=3ZTL9dkDLyosHt_Vg99v2wd9H7mXAAhO229b1gVTuUs-1756350964-1.0.1.1-b4A.vUn2MYiry_9AvgqYz7IFr6jDEpyrlyzvjzH8akXqifj6RVYHJ1hi30dfVbRMN1kN8PnGT2nY0BfQPuPbPfhsDpL42lZsCAcq1zqT0Qk; shell#lang=en; ASP.NET_SessionId=0l4ybgrxsu5fwisd43z4jomw; __RequestVerificationToken=FuPjbI6OtKLWu9RpOQBoyLbfXLBxbCgk1e7a0bBVPD40oyAtWxYg8gc6CNXdoa-pcef-ke3ezCASxUfKalqlIp_JCqs1; _gcl_au=1.1.1920764427.1756350971; firstVisit=1756350970791; firstTime=1756350970792; coveo_visitorId=24eaeeb7-f7f5-406d-abad-b2f963923958; cf_clearance=xRKwOh_KzoMqTTNbAEj9XE8oP6OKgq0XPjp7XoTrQvs-1756350971-1.2.1.1-iVueuiQJU5V8UOXy4zRJgQCWfnlYklrIuN2ex.VJ8ECfGzxBSP2j3jpApgxM6Ktruyg1gZCr9k842kdxpVTgwx8a_u3DVxev2KIyAIAc4cEsJmo0DkDXObKwotPeixy20UCe1G2sOe_6wE7kD_euVneExzLe1MUgxWFwu63KbNwE9u6IZq99OVtHUfIx60DAkvWuc_CVQ9.hMgfunRV0mpJgZjcSAwnso.vQ2HjUa_5hdc6a25mEoVgvPxumx8GR; _fbp=fb.1.1756350971297.69785293643064098; _gid=GA1.2.1760405872.1756350971; _gat=1; SC_ANALYTICS_GLOBAL_COOKIE=9058dc060fd841c9b167ed49dcba6c62|True; _gali=GlobalHeaderSearchBox; _ga_4W67FYNN08=GS2.1.s1756350970$o1$g1$t1756350987$j43$l0$h0; pageCount=2; _ga=GA1.2.2123261700.1756350971",
 	}
+}
 
-	body := fmt.Sprintf(`actionsHistory=%%5B%%7B%%22name%%22%%3A%%22Query%%22%%2C%%22value%%22%%3A%%22%s%%22%%2C%%22time%%22%%3A%%22%%5C%%222025-08-28T03%%3A16%%3A28.543Z%%5C%%22%%22%%7D%%2C%%7B%%22name%%22%%3A%%22PageView%%22%%2C%%22value%%22%%3A%%22514C779641D8497DAA53FE33B3716B88%%22%%2C%%22time%%22%%3A%%222025-08-28T03%%3A16%%3A28.016Z%%22%%7D%%2C%%7B%%22name%%22%%3A%%22PageView%%22%%2C%%22value%%22%%3A%%22110D559FDEA542EA9C1C8A5DF7E70EF9%%22%%2C%%22time%%22%%3A%%222025-08-28T03%%3A16%%3A10.948Z%%22%%7D%%5D&referrer=https%%3A%%2F%%2Fwww.abc.virginia.gov%%2F&analytics=%%7B%%22clientId%%22%%3A%%2224eaeeb7-f7f5-406d-abad-b2f963923958%%22%%2C%%22documentLocation%%22%%3A%%22https%%3A%%2F%%2Fwww.abc.virginia.gov%%2Fsearch-results%%23q%%3D%s%%22%%2C%%22documentReferrer%%22%%3A%%22https%%3A%%2F%%2Fwww.abc.virginia.gov%%2F%%22%%2C%%22pageId%%22%%3A%%22110D559FDEA542EA9C1C8A5DF7E70EF9%%22%%2C%%22actionCause%%22%%3A%%22searchFromLink%%22%%2C%%22customData%%22%%3A%%7B%%22JSUIVersion%%22%%3A%%222.10116.0%%3B2.10116.0%%22%%2C%%22pageFullPath%%22%%3A%%22%%2Fsitecore%%2Fcontent%%2FHome%%2FSearch-Results%%22%%2C%%22sitename%%22%%3A%%22website%%22%%2C%%22siteName%%22%%3A%%22website%%22%%7D%%2C%%22originContext%%22%%3A%%22WebsiteSearch%%22%%7D&visitorId=24eaeeb7-f7f5-406d-abad-b2f963923958&isGuestUser=false&q=%s&aq=(NOT%%20(%%40z95xproductz32xlabelz32xduplicate%%20%%3D%%3D%%20'True'))%%20(%%40z95xresultz32xtype)&cq=(%%40z95xlanguage%%3D%%3Den)%%20(%%40z95xlatestversion%%3D%%3D1)%%20(%%40source%%3D%%3D%%22Coveo_web_index%%20-%%20KubProd2%%22)&searchHub=Search-Results&locale=en&maximumAge=900000&firstResult=0&numberOfResults=12&excerptLength=200&enableDidYouMean=true&sortCriteria=relevancy&queryFunctions=%%5B%%5D&rankingFunctions=%%5B%%5D&groupBy=%%5B%%7B%%22field%%22%%3A%%22%%40z95xresultz32xtype%%22%%2C%%22maximumNumberOfValues%%22%%3A6%%2C%%22sortCriteria%%22%%3A%%22occurrences%%22%%2C%%22injectionDepth%%22%%3A1000%%2C%%22completeFacetWithStandardValues%%22%%3Atrue%%2C%%22allowedValues%%22%%3A%%5B%%5D%%7D%%5D&facetOptions=%%7B%%7D&categoryFacets=%%5B%%5D&retrieveFirstSentences=true&timezone=America%%2FNew_York&enableQuerySyntax=false&enableDuplicateFiltering=false&enableCollaborativeRating=false&debug=false&allowQueriesWithoutKeywords=true`, query, query, query)
+// ScrapeProductsSearch searches the ABC catalog through Coveo the same way the site's search box does
+func ScrapeProductsSearch(ctx context.Context, query string) ([]ProductResult, error) {
+	return searchProducts(ctx, query, PriorityInteractive)
+}
 
-	// Set Content-Length header to the number of characters in the body
-	headers["Content-Length"] = fmt.Sprintf("%d", len(body))
+// searchProducts is ScrapeProductsSearch at the given upstream priority
+func searchProducts(ctx context.Context, query string, priority Priority) ([]ProductResult, error) {
+	run := newRun(RunProductSearch, query)
+	products, err := scrapeProductsSearch(ctx, query, priority)
 
-	resp, err := client.R().
-		SetHeaders(headers).
-		SetBody(body).
-		Post(apiURL)
+	// The browser page load waits on the upstream limiter too, so only fall
+	// back on upstream failures and only while the caller is still waiting
+	var limited *RateLimitedError
+	if err != nil && BrowserFallback && !errors.As(err, &limited) && ctx.Err() == nil {
+		log.Printf("product search API failed, falling back to browser: %v", err)
+		if browserProducts, browserErr := browserProductsSearch(ctx, query, priority); browserErr == nil {
+			products, err = browserProducts, nil
+		} else {
+			log.Printf("browser product search failed: %v", browserErr)
+		}
+	}
+	recordProducts(run, products, err)
+
+	return products, err
+}
 
+func scrapeProductsSearch(ctx context.Context, query string, priority Priority) ([]ProductResult, error) {
+
+	token, err := readToken()
 	if err != nil {
-		return nil, fmt.Errorf("failed to make API request: %w", err)
+		return nil, err
 	}
 
-	// Print response for testing
-	fmt.Printf("Status Code: %d\n", resp.StatusCode())
-	fmt.Printf("Response Body: %s\n", resp.String())
+	headers := productSearchHeaders(token)
+
+	form := url.Values{}
+	form.Set("q", query)
+	form.Set("aq", "(NOT (@z95xproductz32xlabelz32xduplicate == 'True')) (@z95xresultz32xtype)")
+	form.Set("cq", `(@z95xlanguage==en) (@z95xlatestversion==1) (@source=="Coveo_web_index - KubProd2")`)
+	form.Set("searchHub", "Search-Results")
+	form.Set("locale", "en")
+	form.Set("maximumAge", "900000")
+	form.Set("firstResult", "0")
+	form.Set("numberOfResults", "12")
+	form.Set("excerptLength", "200")
+	form.Set("enableDidYouMean", "true")
+	form.Set("sortCriteria", "relevancy")
+	form.Set("queryFunctions", "[]")
+	form.Set("rankingFunctions", "[]")
+	form.Set("groupBy", `[{"field":"@z95xresultz32xtype","maximumNumberOfValues":6,"sortCriteria":"occurrences","injectionDepth":1000,"completeFacetWithStandardValues":true,"allowedValues":[]}]`)
+	form.Set("facetOptions", "{}")
+	form.Set("categoryFacets", "[]")
+	form.Set("retrieveFirstSentences", "true")
+	form.Set("timezone", "America/New_York")
+	form.Set("enableQuerySyntax", "false")
+	form.Set("enableDuplicateFiltering", "false")
+	form.Set("enableCollaborativeRating", "false")
+	form.Set("debug", "false")
+	form.Set("allowQueriesWithoutKeywords", "true")
+
+	resp, err := callABC(ctx, coveoBreaker, priority, resty.MethodPost, productSearchURL, func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+		visitor.search(form, searchResultsPage, query, causeSearchFromLink)
+
+		// Set Content-Length header to the number of characters in the body
+		body := form.Encode()
+		return client.R().
+			SetHeaders(headers).
+			SetHeader("Content-Length", strconv.Itoa(len(body))).
+			SetBody(body)
+	})
+
+	if err != nil {
+		return nil, requestError("coveo", err)
+	}
 
 	// Check if the request was successful
 	if resp.StatusCode() != 200 {
-		return nil, fmt.Errorf("API request failed with status code: %d, response: %s", resp.StatusCode(), resp.String())
+		return nil, statusError("coveo", resp)
 	}
 
+	return parseProductResults(resp.Body())
+}
+
+// parseProductResults converts a Coveo product search response into ProductResult structs
+func parseProductResults(body []byte) ([]ProductResult, error) {
 	var apiResponse struct {
 		Results []struct {
-			Title string `json:"title"`
-			Raw   struct {
-				SysTitle   string   `json:"systitle"`
-				ProductID  string   `json:"z95xproductz32xids"`
-				SizesID    []string `json:"z95xproductz32xskuz32xids"`
-				Sizes      string   `json:"z95xproductz32xsiz122xes"`
-				SizesPrice []string `json:"z95xproductz32xprice"`
-				ABV        float64  `json:"abvmaz120x"`
-				Image      string   `json:"z95ximagez32xurl"`
+			Title    string `json:"title"`
+			ClickURI string `json:"clickUri"`
+			Raw      struct {
+				SysTitle    string   `json:"systitle"`
+				ProductID   string   `json:"z95xproductz32xids"`
+				SizesID     []string `json:"z95xproductz32xskuz32xids"`
+				Sizes       string   `json:"z95xproductz32xsiz122xes"`
+				SizeList    []string `json:"z95xproductz32xsiz122xe"`
+				SizesPrice  []string `json:"z95xproductz32xprice"`
+				ABV         float64  `json:"abvmaz120x"`
+				Image       string   `json:"z95ximagez32xurl"`
+				OnSale      string   `json:"z95xproductz32xonz32xsale"`
+				NewProduct  string   `json:"z95xnewz32xproduct"`
+				Limited     string   `json:"z95xproductz32xlimitedz32xavailability"`
+				Lottery     string   `json:"z95xproductz32xlottery"`
+				Category    string   `json:"hierarchyz32xcategory"`
+				InStore     string   `json:"z95xproductz32xhasz32xstorez32xinventory"`
+				InWarehouse string   `json:"z95xproductz32xhasz32xwarehousez32xinventory"`
+				// The sale fields are not in every index, and their shape is
+				// not documented, so they are decoded tolerantly below
+				Regular   json.RawMessage `json:"z95xproductz32xregularz32xprice"`
+				SaleStart json.RawMessage `json:"z95xproductz32xsalez32xstartz32xdate"`
+				SaleEnd   json.RawMessage `json:"z95xproductz32xsalez32xendz32xdate"`
 			} `json:"raw"`
 		} `json:"results"`
 	}
 
-	if err := json.Unmarshal(resp.Body(), &apiResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, schemaError("coveo", err)
 	}
 
 	// Convert API results to ProductResult structs
@@ -140,16 +221,121 @@ func ScrapeProductsSearch(query string) ([]ProductResult, error) {
 		pricesStr := strings.Join(result.Raw.SizesPrice, ", ")
 
 		products := ProductResult{
-			Title:      result.Raw.SysTitle,
-			ProductID:  productID,
-			Sizes:      result.Raw.Sizes,
-			SizesID:    sizesIDStr,
-			SizesPrice: pricesStr,
-			ABV:        result.Raw.ABV,
-			Image:      imageURL,
+			Title:               result.Raw.SysTitle,
+			ProductID:           productID,
+			Sizes:               result.Raw.Sizes,
+			SizesID:             sizesIDStr,
+			SizesPrice:          pricesStr,
+			ABV:                 result.Raw.ABV,
+			Image:               imageURL,
+			URL:                 result.ClickURI,
+			Category:            result.Raw.Category,
+			OnSale:              result.Raw.OnSale == "1",
+			NewProduct:          result.Raw.NewProduct == "1",
+			LimitedAvailability: result.Raw.Limited == "1",
+			Lottery:             result.Raw.Lottery == "1",
+			StoreInventory:      result.Raw.InStore == "1",
+			WarehouseInventory:  result.Raw.InWarehouse == "1",
+			SaleStart:           coveoDate(result.Raw.SaleStart),
+			SaleEnd:             coveoDate(result.Raw.SaleEnd),
+			Variants:            buildVariants(result.Raw.SizesID, result.Raw.SizeList, result.Raw.SizesPrice, coveoStrings(result.Raw.Regular)),
 		}
 		searchresult = append(searchresult, products)
 	}
 
 	return searchresult, nil
 }
+
+// buildVariants zips the parallel SKU, size and price arrays from Coveo into variants
+func buildVariants(skus, sizes, prices, regularPrices []string) []ProductVariant {
+	variants := make([]ProductVariant, 0, len(skus))
+	for i, sku := range skus {
+		variant := ProductVariant{SKU: sku}
+		if i < len(sizes) {
+			variant.Size = sizes[i]
+		}
+		if i < len(prices) {
+			variant.Price = parsePrice(prices[i])
+		}
+		if i < len(regularPrices) {
+			variant.RegularPrice = parsePrice(regularPrices[i])
+		}
+		variants = append(variants, variant)
+	}
+	return variants
+}
+
+// parsePrice parses a Coveo price such as "22.99" or "$22.99", returning 0 when it is not a number
+func parsePrice(price string) float64 {
+	value, _ := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(price), "$"), 64)
+	return value
+}
+
+// coveoStrings reads a Coveo field that may hold a list or a single value,
+// of strings or numbers, returning nil when it is unset or has another shape
+func coveoStrings(raw json.RawMessage) []string {
+	var values []json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		values = []json.RawMessage{raw}
+	}
+
+	var strs []string
+	for _, value := range values {
+		var str string
+		if err := json.Unmarshal(value, &str); err == nil {
+			strs = append(strs, str)
+			continue
+		}
+		var number json.Number
+		if err := json.Unmarshal(value, &number); err == nil {
+			strs = append(strs, number.String())
+			continue
+		}
+		return nil
+	}
+	return strs
+}
+
+// coveoDate reads a Coveo date field given in epoch milliseconds, as a number
+// or a string, or as an RFC 3339 or Coveo "2006/01/02@15:04:05" string,
+// returning nil when it is unset or cannot be parsed
+func coveoDate(raw json.RawMessage) *time.Time {
+	values := coveoStrings(raw)
+	if len(values) == 0 {
+		return nil
+	}
+	value := strings.TrimSpace(values[0])
+
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if ms <= 0 {
+			return nil
+		}
+		t := time.UnixMilli(ms)
+		return &t
+	}
+	for _, layout := range []string{time.RFC3339, "2006/01/02@15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// LookupVariant finds the product and variant for a SKU by searching Coveo for
+// the SKU code, waiting for the upstream limiter at the given priority
+func LookupVariant(ctx context.Context, sku string, priority Priority) (ProductResult, ProductVariant, error) {
+	products, err := searchProducts(ctx, sku, priority)
+	if err != nil {
+		return ProductResult{}, ProductVariant{}, err
+	}
+
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			if variant.SKU == sku {
+				return product, variant, nil
+			}
+		}
+	}
+
+	return ProductResult{}, ProductVariant{}, fmt.Errorf("%w %s", ErrProductNotFound, sku)
+}
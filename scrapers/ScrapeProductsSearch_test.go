@@ -0,0 +1,83 @@
+package scrapers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCoveoStrings(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`["24.99", "12.99"]`, []string{"24.99", "12.99"}},
+		{`[24.99, 12.99]`, []string{"24.99", "12.99"}},
+		{`"24.99"`, []string{"24.99"}},
+		{`24.99`, []string{"24.99"}},
+		{`{"price": 24.99}`, nil},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		if got := coveoStrings(json.RawMessage(tt.raw)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("coveoStrings(%s) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestCoveoDate(t *testing.T) {
+	want := time.Date(2026, 8, 1, 4, 0, 0, 0, time.UTC)
+	tests := []struct {
+		raw  string
+		want *time.Time
+	}{
+		{`1785556800000`, &want},
+		{`"1785556800000"`, &want},
+		{`"2026-08-01T04:00:00Z"`, &want},
+		{`"2026/08/01@04:00:00"`, &want},
+		{`0`, nil},
+		{`"soon"`, nil},
+		{`{"start": 1}`, nil},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		got := coveoDate(json.RawMessage(tt.raw))
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("coveoDate(%s) = %v, want nil", tt.raw, got)
+		case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+			t.Errorf("coveoDate(%s) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseProductResultsToleratesSaleFieldShapes(t *testing.T) {
+	body := []byte(`{"results": [{
+		"clickUri": "https://www.abc.virginia.gov/products/vodka/titos",
+		"raw": {
+			"systitle": "Tito's Handmade Vodka",
+			"z95xproductz32xids": "Product 010807",
+			"z95xproductz32xskuz32xids": ["010807"],
+			"z95xproductz32xprice": ["19.99"],
+			"z95xproductz32xregularz32xprice": 24.99,
+			"z95xproductz32xsalez32xstartz32xdate": "2026-08-01T04:00:00Z",
+			"z95xproductz32xsalez32xendz32xdate": {"unexpected": true}
+		}
+	}]}`)
+
+	products, err := parseProductResults(body)
+	if err != nil {
+		t.Fatalf("parseProductResults: %v", err)
+	}
+	if len(products) != 1 {
+		t.Fatalf("got %d products, want 1", len(products))
+	}
+	product := products[0]
+	if product.SaleStart == nil || product.SaleEnd != nil {
+		t.Errorf("sale dates: got start %v end %v, want a start and no end", product.SaleStart, product.SaleEnd)
+	}
+	if got := product.Variants[0].RegularPrice; got != 24.99 {
+		t.Errorf("regular price: got %v, want 24.99", got)
+	}
+}
@@ -0,0 +1,63 @@
+package scrapers
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// SaleItem is one variant of a product that is currently on sale
+type SaleItem struct {
+	Title           string     `json:"title"`
+	Category        string     `json:"category"`
+	URL             string     `json:"url"`
+	Image           string     `json:"image"`
+	SKU             string     `json:"sku"`
+	Size            string     `json:"size"`
+	SalePrice       float64    `json:"sale_price"`
+	OriginalPrice   float64    `json:"original_price,omitempty"`
+	DiscountPercent float64    `json:"discount_percent,omitempty"`
+	SaleStart       *time.Time `json:"sale_start,omitempty"`
+	SaleEnd         *time.Time `json:"sale_end,omitempty"`
+}
+
+// ScrapeSales pages through every product Coveo reports as on sale and
+// returns one SaleItem per variant
+func ScrapeSales(ctx context.Context) ([]SaleItem, error) {
+	query := catalogQuery()
+	query.Set("aq", query.Get("aq")+" (@z95xproductz32xonz32xsale==1)")
+
+	run := newRun(RunSales, "")
+	products, err := crawlProducts(ctx, query, PriorityInteractive)
+	recordProducts(run, products, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []SaleItem
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			item := SaleItem{
+				Title:     product.Title,
+				Category:  product.Category,
+				URL:       product.URL,
+				Image:     product.Image,
+				SKU:       variant.SKU,
+				Size:      variant.Size,
+				SalePrice: variant.Price,
+				SaleStart: product.SaleStart,
+				SaleEnd:   product.SaleEnd,
+			}
+
+			// Only report a discount when Coveo gave a higher regular price
+			if variant.RegularPrice > variant.Price && variant.Price > 0 {
+				item.OriginalPrice = variant.RegularPrice
+				item.DiscountPercent = math.Round((1-variant.Price/variant.RegularPrice)*1000) / 10
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,147 @@
+package scrapers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// InventoryBaseURL is the root of the ABC inventory web API. It can be pointed
+// at a local fake inventory server when developing.
+var InventoryBaseURL = "https://www.abc.virginia.gov/webapi/inventory"
+
+// StoreInventory is the stock of one product at one store
+type StoreInventory struct {
+	StoreNumber string `json:"store_number"`
+	Quantity    int    `json:"quantity"`
+	InStock     bool   `json:"in_stock"`
+}
+
+// StoreAvailability is a nearby store together with its stock of a product
+type StoreAvailability struct {
+	StoreResult
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+	InStock  bool   `json:"in_stock"`
+}
+
+// ScrapeStoreInventory looks up the stock of a SKU at each of the given stores,
+// keyed by store number. Stores the endpoint knows nothing about are left out.
+func ScrapeStoreInventory(ctx context.Context, sku string, storeNumbers []string) (map[string]StoreInventory, error) {
+	inventory, err := scrapeInventory(ctx, []string{sku}, storeNumbers)
+	if err != nil {
+		return nil, err
+	}
+	return inventory[sku], nil
+}
+
+// scrapeInventory looks up the stock of several SKUs at the given stores in a
+// single request, keyed by SKU and then store number
+func scrapeInventory(ctx context.Context, skus []string, storeNumbers []string) (map[string]map[string]StoreInventory, error) {
+	resp, err := callABC(ctx, inventoryBreaker, PriorityInteractive, resty.MethodGet, InventoryBaseURL+"/mystore", func(client *resty.Client, _ *visitorRequest) *resty.Request {
+		return client.R().
+			SetHeaders(map[string]string{
+				"Accept":          "application/json, text/plain, */*",
+				"Accept-Language": "en-US,en;q=0.9",
+				"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/132.0.0.0 Safari/537.36",
+				"Referer":         "https://www.abc.virginia.gov/products",
+			}).
+			SetQueryParams(map[string]string{
+				"storeNumbers": strings.Join(storeNumbers, ","),
+				"productCodes": strings.Join(skus, ","),
+			})
+	})
+
+	if err != nil {
+		return nil, requestError("inventory", err)
+	}
+
+	if resp.StatusCode() != 200 {
+		return nil, statusError("inventory", resp)
+	}
+
+	var apiResponse struct {
+		Products []struct {
+			ProductID string `json:"productId"`
+			StoreInfo []struct {
+				StoreID  json.Number `json:"storeId"`
+				Quantity int         `json:"quantity"`
+			} `json:"storeInfo"`
+		} `json:"products"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &apiResponse); err != nil {
+		return nil, schemaError("inventory", err)
+	}
+
+	inventory := make(map[string]map[string]StoreInventory, len(skus))
+	for _, sku := range skus {
+		inventory[sku] = make(map[string]StoreInventory)
+	}
+	for _, product := range apiResponse.Products {
+		stock, ok := inventory[product.ProductID]
+		if !ok {
+			continue
+		}
+		for _, info := range product.StoreInfo {
+			storeNumber := normalizeStoreNumber(info.StoreID.String())
+			stock[storeNumber] = StoreInventory{
+				StoreNumber: storeNumber,
+				Quantity:    info.Quantity,
+				InStock:     info.Quantity > 0,
+			}
+		}
+	}
+
+	return inventory, nil
+}
+
+// ScrapeProductAvailability returns the stores nearest to a zip code along
+// with how many of the given SKU each one has on hand
+func ScrapeProductAvailability(ctx context.Context, sku, zipcode string) ([]StoreAvailability, error) {
+	stores, err := ScrapeUserStore(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	var storeNumbers []string
+	for _, store := range stores {
+		if store.StoreNumber != "" {
+			storeNumbers = append(storeNumbers, store.StoreNumber)
+		}
+	}
+	if len(storeNumbers) == 0 {
+		return nil, fmt.Errorf("no store numbers found near zipcode %s", zipcode)
+	}
+
+	inventory, err := ScrapeStoreInventory(ctx, sku, storeNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory for sku %s: %w", sku, err)
+	}
+
+	availability := make([]StoreAvailability, 0, len(stores))
+	for _, store := range stores {
+		stock := inventory[normalizeStoreNumber(store.StoreNumber)]
+		availability = append(availability, StoreAvailability{
+			StoreResult: store,
+			SKU:         sku,
+			Quantity:    stock.Quantity,
+			InStock:     stock.InStock,
+		})
+	}
+
+	return availability, nil
+}
+
+// normalizeStoreNumber strips leading zeros so "045" from Coveo matches 45 from the inventory API
+func normalizeStoreNumber(storeNumber string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(storeNumber))
+	if err != nil {
+		return storeNumber
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,146 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeInventoryServer serves /mystore the way the ABC inventory API does,
+// with numeric store IDs, and points InventoryBaseURL at it for the test
+func fakeInventoryServer(t *testing.T, body string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mystore" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("productCodes") == "" || r.URL.Query().Get("storeNumbers") == "" {
+			http.Error(w, "missing query parameters", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := InventoryBaseURL
+	InventoryBaseURL = server.URL
+	t.Cleanup(func() { InventoryBaseURL = previous })
+}
+
+const fakeInventoryBody = `{
+	"products": [
+		{"productId": "010807", "storeInfo": [
+			{"storeId": 45, "quantity": 3},
+			{"storeId": 7, "quantity": 0}
+		]},
+		{"productId": "999999", "storeInfo": [
+			{"storeId": 100, "quantity": 12}
+		]}
+	]
+}`
+
+func TestScrapeStoreInventory(t *testing.T) {
+	fakeInventoryServer(t, fakeInventoryBody)
+
+	inventory, err := ScrapeStoreInventory(context.Background(), "010807", []string{"045", "007", "100"})
+	if err != nil {
+		t.Fatalf("ScrapeStoreInventory: %v", err)
+	}
+
+	want := map[string]StoreInventory{
+		"45": {StoreNumber: "45", Quantity: 3, InStock: true},
+		"7":  {StoreNumber: "7", Quantity: 0, InStock: false},
+	}
+	if len(inventory) != len(want) {
+		t.Fatalf("got %d stores, want %d: %+v", len(inventory), len(want), inventory)
+	}
+	for storeNumber, stock := range want {
+		if inventory[storeNumber] != stock {
+			t.Errorf("store %s: got %+v, want %+v", storeNumber, inventory[storeNumber], stock)
+		}
+	}
+}
+
+func TestScrapeProductAvailability(t *testing.T) {
+	fakeInventoryServer(t, fakeInventoryBody)
+
+	// Answer the zip code and nearest stores locally so only the inventory
+	// call goes out, to the fake server
+	geocodeCache.Set("23220", [2]float64{37.55, -77.46})
+	previous := directory
+	directory = &storeDirectory{}
+	t.Cleanup(func() { directory = previous })
+	directory.replace([]StoreResult{
+		{Title: "Broad St", StoreNumber: "045", Latitude: 37.55, Longitude: -77.46},
+		{Title: "Cary St", StoreNumber: "007", Latitude: 37.56, Longitude: -77.47},
+		{Title: "Midlothian", StoreNumber: "100", Latitude: 37.50, Longitude: -77.60},
+	})
+
+	availability, err := ScrapeProductAvailability(context.Background(), "010807", "23220")
+	if err != nil {
+		t.Fatalf("ScrapeProductAvailability: %v", err)
+	}
+
+	want := map[string]struct {
+		quantity int
+		inStock  bool
+	}{
+		"045": {3, true},
+		"007": {0, false},
+		// The endpoint only reported store 100 for another product
+		"100": {0, false},
+	}
+	if len(availability) != len(want) {
+		t.Fatalf("got %d stores, want %d: %+v", len(availability), len(want), availability)
+	}
+	for _, store := range availability {
+		expected, ok := want[store.StoreNumber]
+		if !ok {
+			t.Errorf("unexpected store %s", store.StoreNumber)
+			continue
+		}
+		if store.SKU != "010807" || store.Quantity != expected.quantity || store.InStock != expected.inStock {
+			t.Errorf("store %s: got %+v, want quantity %d in stock %v", store.StoreNumber, store, expected.quantity, expected.inStock)
+		}
+	}
+}
+
+func TestNormalizeStoreNumber(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"045", "45"},
+		{"45", "45"},
+		{" 007 ", "7"},
+		{"0", "0"},
+		{"A12", "A12"},
+	}
+	for _, tt := range tests {
+		if got := normalizeStoreNumber(tt.in); got != tt.want {
+			t.Errorf("normalizeStoreNumber(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestScrapeInventoryBatchesSKUs(t *testing.T) {
+	fakeInventoryServer(t, fakeInventoryBody)
+
+	inventory, err := scrapeInventory(context.Background(), []string{"010807", "999999", "123456"}, []string{"45", "7", "100"})
+	if err != nil {
+		t.Fatalf("scrapeInventory: %v", err)
+	}
+
+	if got := inventory["010807"]["45"].Quantity; got != 3 {
+		t.Errorf("010807 at store 45: got quantity %d, want 3", got)
+	}
+	if got := inventory["999999"]["100"].Quantity; got != 12 {
+		t.Errorf("999999 at store 100: got quantity %d, want 12", got)
+	}
+	if stock, ok := inventory["123456"]; !ok || len(stock) != 0 {
+		t.Errorf("123456: got %+v, want an empty store map", stock)
+	}
+}
@@ -0,0 +1,240 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// ShoppingListItem is one line of a shopping list
+type ShoppingListItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// ShoppingListLine is a priced shopping list item
+type ShoppingListLine struct {
+	SKU       string  `json:"sku"`
+	Title     string  `json:"title"`
+	Size      string  `json:"size"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	LineTotal float64 `json:"line_total"`
+}
+
+// StoreAssignment is a store in a split fulfillment plan and the SKUs to buy there
+type StoreAssignment struct {
+	Store StoreResult `json:"store"`
+	SKUs  []string    `json:"skus"`
+}
+
+// ShoppingPlan is the priced shopping list and where it can be bought near a zip code
+type ShoppingPlan struct {
+	ZipCode string             `json:"zip_code"`
+	Lines   []ShoppingListLine `json:"lines"`
+	Total   float64            `json:"total"`
+
+	// Nearby stores that stock every item, nearest first
+	SingleStores []StoreResult `json:"single_stores"`
+
+	// When no single store stocks everything, a small set of stores that does
+	SplitStores []StoreAssignment `json:"split_stores,omitempty"`
+
+	// SKUs no nearby store has enough of
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+// PlanShoppingList prices a shopping list from variant prices and finds the nearest
+// stores that can fill it, proposing a minimal set of stores when no single one can
+func PlanShoppingList(ctx context.Context, zipcode string, items []ShoppingListItem) (*ShoppingPlan, error) {
+	plan := &ShoppingPlan{ZipCode: zipcode}
+	items = mergeItems(items)
+
+	for _, item := range items {
+		product, variant, err := LookupVariant(ctx, item.SKU, PriorityInteractive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to price sku %s: %w", item.SKU, err)
+		}
+
+		line := ShoppingListLine{
+			SKU:       item.SKU,
+			Title:     product.Title,
+			Size:      variant.Size,
+			Quantity:  item.Quantity,
+			UnitPrice: variant.Price,
+			LineTotal: roundCents(variant.Price * float64(item.Quantity)),
+		}
+		plan.Lines = append(plan.Lines, line)
+		plan.Total += line.LineTotal
+	}
+	plan.Total = roundCents(plan.Total)
+
+	stores, err := ScrapeUserStore(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	var storeNumbers []string
+	for _, store := range stores {
+		if store.StoreNumber != "" {
+			storeNumbers = append(storeNumbers, store.StoreNumber)
+		}
+	}
+	if len(storeNumbers) == 0 {
+		return nil, fmt.Errorf("no store numbers found near zipcode %s", zipcode)
+	}
+
+	// For each store, which SKUs it has enough of
+	stocked := make([]map[string]bool, len(stores))
+	for i := range stocked {
+		stocked[i] = make(map[string]bool)
+	}
+
+	// One inventory request for the whole list
+	skus := make([]string, len(items))
+	for i, item := range items {
+		skus[i] = item.SKU
+	}
+	inventory, err := scrapeInventory(ctx, skus, storeNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory for shopping list: %w", err)
+	}
+
+	for _, item := range items {
+		found := false
+		for i, store := range stores {
+			if inventory[item.SKU][normalizeStoreNumber(store.StoreNumber)].Quantity >= item.Quantity {
+				stocked[i][item.SKU] = true
+				found = true
+			}
+		}
+		if !found {
+			plan.Unavailable = append(plan.Unavailable, item.SKU)
+		}
+	}
+
+	plan.SingleStores = []StoreResult{}
+	for i, store := range stores {
+		if len(stocked[i]) == len(items) {
+			plan.SingleStores = append(plan.SingleStores, store)
+		}
+	}
+
+	if len(plan.SingleStores) == 0 {
+		plan.SplitStores = coverStores(stores, stocked, items, plan.Unavailable)
+	}
+
+	return plan, nil
+}
+
+// maxCoverStores bounds how many of the nearest stores coverStores tries
+// subsets of, so the search stays small
+const maxCoverStores = 12
+
+// coverStores finds the fewest stores that together stock every obtainable
+// SKU by trying every subset of the nearest stores, preferring nearer stores
+// among equally small sets. Each SKU is bought at the nearest chosen store
+// that has it.
+func coverStores(stores []StoreResult, stocked []map[string]bool, items []ShoppingListItem, unavailable []string) []StoreAssignment {
+	n := min(len(stores), maxCoverStores)
+
+	skip := make(map[string]bool)
+	for _, sku := range unavailable {
+		skip[sku] = true
+	}
+
+	// SKUs some store in the search stocks; any others cannot be covered
+	var needed []string
+	for _, item := range items {
+		if skip[item.SKU] {
+			continue
+		}
+		for i := 0; i < n; i++ {
+			if stocked[i][item.SKU] {
+				needed = append(needed, item.SKU)
+				break
+			}
+		}
+	}
+	if len(needed) == 0 {
+		return nil
+	}
+
+	best, bestSize, bestRank := 0, n+1, 0
+	for subset := 1; subset < 1<<n; subset++ {
+		size := bits.OnesCount(uint(subset))
+		if size > bestSize {
+			continue
+		}
+
+		// Stores are nearest first, so a lower sum of indexes is a nearer set
+		rank := 0
+		for i := 0; i < n; i++ {
+			if subset&(1<<i) != 0 {
+				rank += i
+			}
+		}
+		if size == bestSize && rank >= bestRank {
+			continue
+		}
+
+		if coversAll(subset, n, stocked, needed) {
+			best, bestSize, bestRank = subset, size, rank
+		}
+	}
+
+	assigned := make(map[string]bool)
+	var assignments []StoreAssignment
+	for i := 0; i < n; i++ {
+		if best&(1<<i) == 0 {
+			continue
+		}
+
+		assignment := StoreAssignment{Store: stores[i]}
+		for _, sku := range needed {
+			if stocked[i][sku] && !assigned[sku] {
+				assignment.SKUs = append(assignment.SKUs, sku)
+				assigned[sku] = true
+			}
+		}
+		sort.Strings(assignment.SKUs)
+		assignments = append(assignments, assignment)
+	}
+
+	return assignments
+}
+
+// coversAll reports whether the stores in subset together stock every needed SKU
+func coversAll(subset, n int, stocked []map[string]bool, needed []string) bool {
+	for _, sku := range needed {
+		covered := false
+		for i := 0; i < n && !covered; i++ {
+			covered = subset&(1<<i) != 0 && stocked[i][sku]
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeItems combines repeated SKUs on a shopping list into one line, keeping list order
+func mergeItems(items []ShoppingListItem) []ShoppingListItem {
+	index := make(map[string]int)
+	var merged []ShoppingListItem
+	for _, item := range items {
+		if i, ok := index[item.SKU]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.SKU] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
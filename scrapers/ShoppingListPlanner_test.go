@@ -0,0 +1,53 @@
+package scrapers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoverStoresIsMinimal(t *testing.T) {
+	stores := []StoreResult{{StoreNumber: "1"}, {StoreNumber: "2"}, {StoreNumber: "3"}}
+	stocked := []map[string]bool{
+		// Stocks the most SKUs, so a greedy cover would start here and need all three stores
+		{"a": true, "b": true, "c": true, "d": true},
+		{"a": true, "b": true, "e": true},
+		{"c": true, "d": true, "f": true},
+	}
+	items := []ShoppingListItem{{SKU: "a"}, {SKU: "b"}, {SKU: "c"}, {SKU: "d"}, {SKU: "e"}, {SKU: "f"}, {SKU: "g"}}
+
+	got := coverStores(stores, stocked, items, []string{"g"})
+	want := []StoreAssignment{
+		{Store: stores[1], SKUs: []string{"a", "b", "e"}},
+		{Store: stores[2], SKUs: []string{"c", "d", "f"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coverStores = %+v, want %+v", got, want)
+	}
+}
+
+func TestCoverStoresPrefersNearerStores(t *testing.T) {
+	stores := []StoreResult{{StoreNumber: "1"}, {StoreNumber: "2"}, {StoreNumber: "3"}}
+	stocked := []map[string]bool{
+		{"a": true},
+		{"b": true},
+		{"a": true, "b": true},
+	}
+	items := []ShoppingListItem{{SKU: "a"}, {SKU: "b"}}
+
+	got := coverStores(stores, stocked, items, nil)
+	want := []StoreAssignment{{Store: stores[2], SKUs: []string{"a", "b"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coverStores = %+v, want %+v", got, want)
+	}
+
+	// Between two equally small sets the nearer stores win
+	stocked[2] = map[string]bool{"a": true}
+	got = coverStores(stores, stocked, items, nil)
+	want = []StoreAssignment{
+		{Store: stores[0], SKUs: []string{"a"}},
+		{Store: stores[1], SKUs: []string{"b"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coverStores = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// nearestStoreCount is how many stores a nearest-store search returns
+	nearestStoreCount = 10
+
+	// storeDirectoryPageSize is how many stores are requested per Coveo page while syncing
+	storeDirectoryPageSize = 100
+)
+
+// StoreDirectoryMaxAge is how long a synced store directory is trusted for
+// nearest-store queries before ScrapeUserStore falls back to Coveo
+var StoreDirectoryMaxAge = 24 * time.Hour
+
+// storeDirectory holds every ABC store from the last directory sync
+type storeDirectory struct {
+	mu       sync.RWMutex
+	stores   []StoreResult
+	index    *storeIndex
+	syncedAt time.Time
+}
+
+var directory = &storeDirectory{}
+
+// nearest answers a nearest-store query from the directory, or returns nil
+// when the directory has never been synced or is older than StoreDirectoryMaxAge
+func (d *storeDirectory) nearest(lat, lng float64, k int) []StoreResult {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.index == nil || time.Since(d.syncedAt) > StoreDirectoryMaxAge {
+		return nil
+	}
+
+	return d.index.nearest(lat, lng, k)
+}
+
+// replace swaps in a freshly synced list of stores and rebuilds the index
+func (d *storeDirectory) replace(stores []StoreResult) {
+	index := newStoreIndex(stores)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stores = stores
+	d.index = index
+	d.syncedAt = time.Now()
+}
+
+// StoreDirectory returns a copy of the synced store directory and when it was synced.
+// The returned time is zero if the directory has never been synced.
+func StoreDirectory() ([]StoreResult, time.Time) {
+	directory.mu.RLock()
+	defer directory.mu.RUnlock()
+
+	stores := make([]StoreResult, len(directory.stores))
+	copy(stores, directory.stores)
+
+	return stores, directory.syncedAt
+}
+
+// SyncStoreDirectory pages through every store in the Coveo index and
+// replaces the local store directory with the result
+func SyncStoreDirectory(ctx context.Context) (int, error) {
+	run := newRun(RunStoreDirectory, "")
+	stores, err := fetchStoreDirectory(ctx)
+	recordStores(run, stores, err)
+	if err != nil {
+		return 0, err
+	}
+
+	directory.replace(stores)
+
+	return len(stores), nil
+}
+
+// fetchStoreDirectory pages through every store in the Coveo store index
+func fetchStoreDirectory(ctx context.Context) ([]StoreResult, error) {
+	token, err := readToken()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := storeSearchHeaders(token)
+
+	var stores []StoreResult
+	for firstResult := 0; ; firstResult += storeDirectoryPageSize {
+		form := url.Values{}
+		form.Set("aq", "(@z95xtemplate==A1A81C71EB254BCFB9686611212A840B)")
+		form.Set("cq", `((@z95xlanguage==en) (@z95xlatestversion==1) (@source=="Coveo_web_index - KubProd2"))`)
+		form.Set("searchHub", "StoresSearchHub")
+		form.Set("locale", "en")
+		form.Set("pipeline", "Stores")
+		form.Set("maximumAge", "900000")
+		form.Set("firstResult", strconv.Itoa(firstResult))
+		form.Set("numberOfResults", strconv.Itoa(storeDirectoryPageSize))
+		form.Set("sortCriteria", "@title ascending")
+		form.Set("timezone", "America/New_York")
+		form.Set("enableDidYouMean", "false")
+		form.Set("enableQuerySyntax", "false")
+		form.Set("enableDuplicateFiltering", "false")
+		form.Set("debug", "false")
+		form.Set("allowQueriesWithoutKeywords", "true")
+
+		cause := causePagerNext
+		if firstResult == 0 {
+			cause = causeInterfaceLoad
+		}
+
+		resp, err := callABC(ctx, coveoBreaker, PriorityBackground, resty.MethodPost, storeSearchURL, func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+			visitor.search(form, storesPage, "", cause)
+
+			return client.R().
+				SetHeaders(headers).
+				SetBody(form.Encode())
+		})
+
+		if err != nil {
+			return nil, requestError("coveo", err)
+		}
+
+		if resp.StatusCode() != 200 {
+			return nil, statusError("coveo", resp)
+		}
+
+		page, err := parseStoreResults(resp.Body(), StoreSourceDirectory)
+		if err != nil {
+			return nil, err
+		}
+
+		stores = append(stores, page...)
+		if len(page) < storeDirectoryPageSize {
+			break
+		}
+	}
+
+	if len(stores) == 0 {
+		return nil, fmt.Errorf("store directory sync returned no stores")
+	}
+
+	return stores, nil
+}
@@ -0,0 +1,191 @@
+package scrapers
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// coveoVisitorIdle is how long a visitor can go without searching before the
+// next search starts over as a new visitor
+const coveoVisitorIdle = 30 * time.Minute
+
+// coveoHistorySize caps actionsHistory the way Coveo's search UI does
+const coveoHistorySize = 20
+
+// Action causes Coveo's search UI reports for the searches we make
+const (
+	causeSearchFromLink = "searchFromLink"
+	causeAdvancedSearch = "advancedSearch"
+	causeInterfaceLoad  = "interfaceLoad"
+	causePagerNext      = "pagerNext"
+)
+
+// coveoPage is a page of the site a Coveo search can be sent from
+type coveoPage struct {
+	// ID is the page's Sitecore item ID, as recorded in PageView actions
+	ID       string
+	Location string
+	FullPath string
+}
+
+// Pages the scrapers' searches pretend to come from
+var (
+	homePage          = coveoPage{ID: "110D559FDEA542EA9C1C8A5DF7E70EF9", Location: "https://www.abc.virginia.gov/", FullPath: "/sitecore/content/Home"}
+	searchResultsPage = coveoPage{ID: "514C779641D8497DAA53FE33B3716B88", Location: "https://www.abc.virginia.gov/search-results", FullPath: "/sitecore/content/Home/Search-Results"}
+	storesPage        = coveoPage{ID: "712668CA41D0461EB27D4D8E1D35FFD0", Location: "https://www.abc.virginia.gov/stores", FullPath: "/sitecore/content/Home/Stores"}
+)
+
+// coveoAction is one entry of the actionsHistory Coveo's search UI keeps
+type coveoAction struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Time  string `json:"time"`
+}
+
+// coveoVisitor simulates one visitor to the site, so the analytics sent with
+// searches carry a stable visitor ID and a history that moves with the clock
+type coveoVisitor struct {
+	mu       sync.Mutex
+	id       string
+	history  []coveoAction
+	page     coveoPage
+	referrer string
+	lastSeen time.Time
+}
+
+// directVisitor is the visitor for requests that do not go through a proxy
+var directVisitor = &coveoVisitor{}
+
+// search adds the visitor and analytics fields Coveo's search UI sends to a
+// search from page. query is what shows in the page's #q= fragment; a Query
+// action is recorded only for causeSearchFromLink.
+func (v *coveoVisitor) search(form url.Values, page coveoPage, query, cause string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if v.id == "" || now.Sub(v.lastSeen) > coveoVisitorIdle {
+		v.start(now)
+	}
+	previous := v.lastSeen
+	v.lastSeen = now
+
+	// Land on the page a moment before searching from it, but after the
+	// visitor's previous search
+	if v.page.ID != page.ID {
+		landed := now.Add(-jitter(300*time.Millisecond, time.Second))
+		if !landed.After(previous) {
+			landed = previous.Add(now.Sub(previous) / 2)
+		}
+
+		v.referrer = v.page.Location
+		v.page = page
+		v.record("PageView", page.ID, landed)
+	}
+	if cause == causeSearchFromLink {
+		// The search UI JSON-encodes the time of Query actions twice
+		v.record("Query", query, now)
+		v.history[0].Time = fmt.Sprintf("%q", v.history[0].Time)
+	}
+
+	location := page.Location
+	if query != "" {
+		location += "#q=" + url.QueryEscape(query)
+	}
+
+	history, _ := json.Marshal(v.history)
+	analytics, _ := json.Marshal(map[string]interface{}{
+		"clientId":         v.id,
+		"documentLocation": location,
+		"documentReferrer": v.referrer,
+		"pageId":           page.ID,
+		"actionCause":      cause,
+		"customData": map[string]string{
+			"JSUIVersion":  "2.10116.0;2.10116.0",
+			"pageFullPath": page.FullPath,
+			"sitename":     "website",
+			"siteName":     "website",
+		},
+		"originContext": "WebsiteSearch",
+	})
+
+	form.Set("actionsHistory", string(history))
+	form.Set("referrer", v.referrer)
+	form.Set("analytics", string(analytics))
+	form.Set("visitorId", v.id)
+	form.Set("isGuestUser", "false")
+}
+
+// analyticsFields are the form fields search fills in
+var analyticsFields = []string{"actionsHistory", "referrer", "analytics", "visitorId", "isGuestUser"}
+
+// visitorRequest is a visitor as seen by one logical request. callABC may
+// build a request again after a Cloudflare challenge or a blocked proxy, but
+// the visitor's actions are recorded once and the same analytics resent.
+type visitorRequest struct {
+	visitor *coveoVisitor
+	fields  url.Values
+}
+
+// search fills in form like coveoVisitor.search the first time it is called
+// and with the same analytics after that
+func (r *visitorRequest) search(form url.Values, page coveoPage, query, cause string) {
+	if r.fields == nil {
+		r.visitor.search(form, page, query, cause)
+		r.fields = url.Values{}
+		for _, key := range analyticsFields {
+			r.fields[key] = form[key]
+		}
+	}
+	for key, values := range r.fields {
+		form[key] = values
+	}
+}
+
+// cookie returns the coveo_visitorId cookie the site's search UI keeps for
+// the visitor, or nil if the request made no search
+func (r *visitorRequest) cookie() *http.Cookie {
+	if r.fields.Get("visitorId") == "" {
+		return nil
+	}
+	return &http.Cookie{Name: "coveo_visitorId", Value: r.fields.Get("visitorId")}
+}
+
+// start begins a new visitor who arrived on the home page a little while ago
+func (v *coveoVisitor) start(now time.Time) {
+	v.id = newVisitorID()
+	v.history = nil
+	v.page = homePage
+	v.referrer = homePage.Location
+	v.lastSeen = now.Add(-jitter(5*time.Second, time.Minute))
+	v.record("PageView", homePage.ID, v.lastSeen)
+}
+
+// record adds an action to the front of the history, dropping the oldest
+func (v *coveoVisitor) record(name, value string, at time.Time) {
+	action := coveoAction{Name: name, Value: value, Time: at.UTC().Format("2006-01-02T15:04:05.000Z")}
+	v.history = append([]coveoAction{action}, v.history...)
+	if len(v.history) > coveoHistorySize {
+		v.history = v.history[:coveoHistorySize]
+	}
+}
+
+// Helper function to generate a random version 4 UUID for a visitor
+func newVisitorID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Helper function to pick a random duration between min and max
+func jitter(min, max time.Duration) time.Duration {
+	return min + mathrand.N(max-min)
+}
@@ -0,0 +1,82 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestRetriedSearchIsRecordedOnce(t *testing.T) {
+	type sent struct {
+		visitorID, cookie, history string
+	}
+	var requests []sent
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		r.ParseForm()
+		request := sent{visitorID: r.PostForm.Get("visitorId"), history: r.PostForm.Get("actionsHistory")}
+		if cookie, err := r.Cookie("coveo_visitorId"); err == nil {
+			request.cookie = cookie.Value
+		}
+		requests = append(requests, request)
+
+		// Block the first attempt so callABC builds the request again
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer proxy.Close()
+
+	err := ConfigureProxyPool(ProxyPoolConfig{
+		URLs:                []string{proxy.URL},
+		HealthCheckURL:      "http://www.abc.virginia.gov.test/",
+		HealthCheckInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProxyPool: %v", err)
+	}
+	t.Cleanup(func() { ConfigureProxyPool(ProxyPoolConfig{}) })
+
+	form := url.Values{"q": {"bourbon"}}
+	resp, err := callABC(context.Background(), newCircuitBreaker("test", 5, time.Minute), PriorityInteractive, http.MethodPost,
+		"http://www.abc.virginia.gov.test/coveo/rest/search/v2",
+		func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+			visitor.search(form, searchResultsPage, "bourbon", causeSearchFromLink)
+			return client.R().
+				SetHeader("Content-Type", "application/x-www-form-urlencoded").
+				SetBody(form.Encode())
+		})
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		t.Fatalf("callABC = %v, %v", resp, err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	visitor := proxies.proxies[0].visitor
+	for i, request := range requests {
+		if request.visitorID != visitor.id || request.cookie != visitor.id {
+			t.Errorf("request %d: visitorId %q, cookie %q, want both %q", i, request.visitorID, request.cookie, visitor.id)
+		}
+	}
+	if requests[0].history != requests[1].history {
+		t.Error("the retry sent a different actionsHistory")
+	}
+
+	queries := 0
+	for _, action := range visitor.history {
+		if action.Name == "Query" {
+			queries++
+		}
+	}
+	if queries != 1 {
+		t.Errorf("visitor recorded %d Query actions, want 1", queries)
+	}
+}
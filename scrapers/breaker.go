@@ -0,0 +1,263 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// UpstreamUnavailableError is returned without calling a dependency whose
+// circuit is open because its recent calls kept failing
+type UpstreamUnavailableError struct {
+	Dependency string
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamUnavailableError) Error() string {
+	return fmt.Sprintf("upstream %s unavailable, retry after %s", e.Dependency, e.RetryAfter.Round(time.Second))
+}
+
+// notSentError wraps the reason a call through a breaker gave up before the
+// request was sent, such as a deadline passing while waiting on the upstream
+// limiter. It reads and unwraps as the reason itself.
+type notSentError struct {
+	err error
+}
+
+func (e *notSentError) Error() string { return e.err.Error() }
+
+func (e *notSentError) Unwrap() error { return e.err }
+
+// CircuitStatus is a snapshot of one dependency's circuit breaker
+type CircuitStatus struct {
+	Dependency          string     `json:"dependency"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	Failures            uint64     `json:"failures"`
+	Rejected            uint64     `json:"rejected"`
+	Opens               uint64     `json:"opens"`
+}
+
+// circuitBreaker opens after threshold consecutive failures, rejects calls
+// for cooldown, then lets a single probe call through to decide whether to
+// close again
+type circuitBreaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	// Lifetime counters for metrics
+	totalFailures uint64
+	rejected      uint64
+	opens         uint64
+}
+
+// Breakers for each upstream dependency
+var (
+	coveoBreaker     = newCircuitBreaker("coveo", 5, 30*time.Second)
+	nominatimBreaker = newCircuitBreaker("nominatim", 5, 30*time.Second)
+	inventoryBreaker = newCircuitBreaker("inventory", 5, 30*time.Second)
+)
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{name: name, threshold: max(threshold, 1), cooldown: cooldown, state: CircuitClosed}
+}
+
+// ConfigureCircuitBreakers sets how many consecutive failures open a circuit
+// and how long it stays open before a probe call is allowed
+func ConfigureCircuitBreakers(threshold int, cooldown time.Duration) {
+	for _, b := range []*circuitBreaker{coveoBreaker, nominatimBreaker, inventoryBreaker} {
+		b.mu.Lock()
+		b.threshold = max(threshold, 1)
+		b.cooldown = cooldown
+		b.mu.Unlock()
+	}
+}
+
+// Circuits returns the state of every upstream circuit breaker
+func Circuits() []CircuitStatus {
+	var statuses []CircuitStatus
+	for _, b := range []*circuitBreaker{coveoBreaker, nominatimBreaker, inventoryBreaker} {
+		statuses = append(statuses, b.status())
+	}
+	return statuses
+}
+
+func (b *circuitBreaker) status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := CircuitStatus{
+		Dependency:          b.name,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		Failures:            b.totalFailures,
+		Rejected:            b.rejected,
+		Opens:               b.opens,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow reports whether a call may go ahead, moving an open circuit to
+// half-open once its cooldown is over
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+			b.rejected++
+			return &UpstreamUnavailableError{Dependency: b.name, RetryAfter: wait}
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		// Only one probe at a time while half-open
+		if b.probing {
+			b.rejected++
+			return &UpstreamUnavailableError{Dependency: b.name, RetryAfter: time.Second}
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// record reports the outcome of an allowed call
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.totalFailures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			b.opens++
+		}
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abort gives up an allowed call that was never sent
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// do sends a request through the breaker. Transport errors, 5xx responses
+// and 429s count as failures; other responses mean the dependency is up.
+func (b *circuitBreaker) do(send func() (*resty.Response, error)) (*resty.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := send()
+
+	// Never sent, or abandoned by the caller, so nothing was learned about
+	// the dependency
+	var notSent *notSentError
+	if errors.As(err, &notSent) || errors.Is(err, context.Canceled) {
+		b.abort()
+		return nil, err
+	}
+
+	b.record(err == nil && resp.StatusCode() < http.StatusInternalServerError && resp.StatusCode() != http.StatusTooManyRequests)
+
+	return resp, err
+}
+
+// callABC sends a request to abc.virginia.gov through a dependency's breaker
+// and the shared upstream limiter, via the next pooled proxy if there are any,
+// in which case the request carries that proxy's bearer token.
+// build returns the request ready to send on the given client, carrying the
+// analytics of that route's visitor, so it can be rebuilt and retried once
+// after a Cloudflare challenge is passed, or through another proxy after this
+// one was blocked. Each visitor records the search once however often it is built.
+func callABC(ctx context.Context, breaker *circuitBreaker, priority Priority, method, url string, build func(client *resty.Client, visitor *visitorRequest) *resty.Request) (*resty.Response, error) {
+	visitors := make(map[*coveoVisitor]*visitorRequest)
+	buildFor := func(client *resty.Client, visitor *coveoVisitor) *resty.Request {
+		if visitors[visitor] == nil {
+			visitors[visitor] = &visitorRequest{visitor: visitor}
+		}
+		req := build(client, visitors[visitor])
+		if cookie := visitors[visitor].cookie(); cookie != nil {
+			req.SetCookie(cookie)
+		}
+		return req.SetContext(ctx)
+	}
+
+	for retried := false; ; retried = true {
+		proxy, err := pickProxy()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := breaker.do(func() (*resty.Response, error) {
+			release, err := acquireUpstream(ctx, priority)
+			if err != nil {
+				return nil, &notSentError{err}
+			}
+			defer release()
+
+			if proxy != nil {
+				return proxy.pinToken(buildFor(proxy.client, proxy.visitor)).Execute(method, url)
+			}
+
+			req := buildFor(httpClient(), directVisitor)
+			clearance.apply(req)
+			return req.Execute(method, url)
+		})
+		if err != nil {
+			return resp, err
+		}
+
+		// The browser's clearance is tied to its own address, so a proxy that
+		// gets challenged is rotated away from instead of handed off
+		if proxy != nil {
+			if proxy.blocked(resp) && !retried {
+				continue
+			}
+			retried = true
+		}
+
+		retry, err := handleCloudflare(ctx, resp, retried)
+		if !retry {
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestBreakerIgnoresCallsThatWereNeverSent(t *testing.T) {
+	b := newCircuitBreaker("test", 2, time.Minute)
+
+	// The deadline passed while waiting on the limiter
+	for range 5 {
+		_, err := b.do(func() (*resty.Response, error) {
+			return nil, &notSentError{context.DeadlineExceeded}
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("do = %v, want the deadline error", err)
+		}
+	}
+	if b.state != CircuitClosed || b.failures != 0 {
+		t.Fatalf("after unsent calls: state %s with %d failures, want closed with none", b.state, b.failures)
+	}
+
+	// A request that was sent and timed out does count
+	for range 2 {
+		b.do(func() (*resty.Response, error) {
+			return nil, context.DeadlineExceeded
+		})
+	}
+	if b.state != CircuitOpen {
+		t.Errorf("after sent timeouts: state %s, want open", b.state)
+	}
+}
+
+func TestNotSentErrorKeepsItsReason(t *testing.T) {
+	limited := &RateLimitedError{RetryAfter: time.Second}
+	var asLimited *RateLimitedError
+	if err := error(&notSentError{limited}); !errors.As(err, &asLimited) || err.Error() != limited.Error() {
+		t.Errorf("notSentError hides the rate limit: %v", err)
+	}
+
+	if err := requestError("coveo", &notSentError{context.DeadlineExceeded}); !errors.Is(err, ErrUpstreamTimeout) {
+		t.Errorf("requestError = %v, want ErrUpstreamTimeout", err)
+	}
+}
@@ -0,0 +1,87 @@
+package scrapers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ABCScraper/browser"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BrowserBaseURL is the site the browser scrapers load pages from. It can be
+// pointed at a local server serving saved pages when developing.
+var BrowserBaseURL = "https://www.abc.virginia.gov"
+
+// browserPool runs every browser task the scrapers have. Its browsers only
+// start when a task first needs one.
+var (
+	browserPoolMu sync.Mutex
+	browserPool   = browser.NewPool(browserConfig(browser.DefaultConfig))
+)
+
+// ConfigureBrowserPool replaces the browser pool, stopping the old one's browsers
+func ConfigureBrowserPool(config browser.Config) {
+	browserPoolMu.Lock()
+	defer browserPoolMu.Unlock()
+
+	browserPool.Close()
+	browserPool = browser.NewPool(browserConfig(config))
+}
+
+// CloseBrowserPool stops the pooled browsers
+func CloseBrowserPool() {
+	browserPoolMu.Lock()
+	defer browserPoolMu.Unlock()
+
+	browserPool.Close()
+}
+
+// browserConfig fills in the user agent the cf_clearance cookie is tied to
+func browserConfig(config browser.Config) browser.Config {
+	if config.UserAgent == "" {
+		config.UserAgent = browserUserAgent
+	}
+	return config
+}
+
+// runBrowser runs actions in a pooled browser tab. A timeout of zero leaves
+// only the pool's own task timeout.
+func runBrowser(ctx context.Context, timeout time.Duration, actions ...chromedp.Action) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	browserPoolMu.Lock()
+	pool := browserPool
+	browserPoolMu.Unlock()
+
+	return pool.Run(ctx, func(ctx context.Context) error {
+		return chromedp.Run(ctx, actions...)
+	})
+}
+
+// waitForChallenge waits for a Cloudflare "Just a moment..." interstitial to
+// hand over to the site
+func waitForChallenge() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for {
+			var title string
+			if err := chromedp.Title(&title).Do(ctx); err != nil {
+				return err
+			}
+			if title != "Just a moment..." && title != "" {
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ClientConfig tunes the HTTP client every scraper sends upstream requests through
+type ClientConfig struct {
+	// Timeout bounds a single attempt, including reading the body
+	Timeout time.Duration
+	// Proxy, when set, is the URL of an HTTP proxy for every upstream request.
+	// Otherwise the usual HTTPS_PROXY environment variables apply.
+	Proxy               string
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	// RetryCount is how many times an idempotent request is sent again after
+	// a transport error or a 502/503/504, waiting a jittered exponential
+	// backoff between RetryWait and RetryMaxWait
+	RetryCount   int
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+}
+
+// DefaultClientConfig is used until ConfigureUpstreamClient is called
+var DefaultClientConfig = ClientConfig{
+	Timeout:             30 * time.Second,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	RetryCount:          2,
+	RetryWait:           250 * time.Millisecond,
+	RetryMaxWait:        3 * time.Second,
+}
+
+// upstreamClient is long-lived so keep-alive connections and TLS sessions
+// to abc.virginia.gov and Nominatim are reused between requests
+var (
+	upstreamClientMu     sync.RWMutex
+	upstreamClient       = newUpstreamClient(DefaultClientConfig, nil)
+	upstreamClientConfig = DefaultClientConfig
+)
+
+// ConfigureUpstreamClient replaces the upstream client with one built from config
+func ConfigureUpstreamClient(config ClientConfig) error {
+	var proxy *url.URL
+	if config.Proxy != "" {
+		var err error
+		if proxy, err = url.Parse(config.Proxy); err != nil {
+			return fmt.Errorf("invalid upstream proxy %q: %w", config.Proxy, err)
+		}
+	}
+
+	upstreamClientMu.Lock()
+	defer upstreamClientMu.Unlock()
+
+	upstreamClient = newUpstreamClient(config, proxy)
+	upstreamClientConfig = config
+	return nil
+}
+
+// SetUpstreamClient injects the client the scrapers send requests through,
+// e.g. one pointed at a test server
+func SetUpstreamClient(client *resty.Client) {
+	upstreamClientMu.Lock()
+	defer upstreamClientMu.Unlock()
+
+	upstreamClient = client
+}
+
+// httpClient returns the shared upstream client
+func httpClient() *resty.Client {
+	upstreamClientMu.RLock()
+	defer upstreamClientMu.RUnlock()
+
+	return upstreamClient
+}
+
+// currentClientConfig returns the settings the upstream client was last configured with
+func currentClientConfig() ClientConfig {
+	upstreamClientMu.RLock()
+	defer upstreamClientMu.RUnlock()
+
+	return upstreamClientConfig
+}
+
+// Helper function to build a client with a tuned transport and retry policy
+func newUpstreamClient(config ClientConfig, proxy *url.URL) *resty.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = config.MaxIdleConns
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	client := resty.New().
+		SetTransport(transport).
+		SetTimeout(config.Timeout).
+		SetRetryCount(config.RetryCount).
+		SetRetryWaitTime(config.RetryWait).
+		SetRetryMaxWaitTime(config.RetryMaxWait).
+		AddRetryCondition(retryable)
+
+	// Requests used to get a fresh client each, so cookies never carried over.
+	// Keep it that way; the Cloudflare clearance is attached explicitly.
+	client.SetCookieJar(nil)
+
+	return client
+}
+
+// retryable reports whether a failed attempt should be sent again. Retries
+// happen within one upstream limiter slot and count once against the breaker.
+func retryable(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil || !idempotent(resp.Request) {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	case http.StatusServiceUnavailable:
+		// A challenge needs the browser handoff, not another attempt
+		return !isCloudflareChallenge(resp)
+	}
+	return false
+}
+
+// idempotent reports whether a request can safely be sent twice. Coveo
+// searches are POSTed but only read the index.
+func idempotent(req *resty.Request) bool {
+	switch req.Method {
+	case resty.MethodGet, resty.MethodHead, resty.MethodOptions:
+		return true
+	}
+	return strings.Contains(req.URL, "/coveo/rest/search/")
+}
@@ -0,0 +1,90 @@
+package scrapers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestRetryableRequests(t *testing.T) {
+	var hits atomic.Int32
+	challenge := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if challenge {
+			w.Header().Set("cf-mitigated", "challenge")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig
+	config.RetryCount = 2
+	config.RetryWait = time.Millisecond
+	config.RetryMaxWait = time.Millisecond
+	client := newUpstreamClient(config, nil)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		challenge bool
+		attempts  int32
+	}{
+		{"GET is retried", http.MethodGet, "/mystore", false, 3},
+		{"search POST is retried", http.MethodPost, "/coveo/rest/search/v2", false, 3},
+		{"other POST is sent once", http.MethodPost, "/api/cart/add", false, 1},
+		{"challenge is not retried", http.MethodGet, "/mystore", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits.Store(0)
+			challenge = tt.challenge
+
+			if _, err := client.R().Execute(tt.method, server.URL+tt.path); err != nil {
+				t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+			}
+			if got := hits.Load(); got != tt.attempts {
+				t.Errorf("%s %s was sent %d times, want %d", tt.method, tt.path, got, tt.attempts)
+			}
+		})
+	}
+}
+
+// BenchmarkUpstreamClient compares sending through the shared upstream client,
+// which reuses TLS connections, with building a client per request as the
+// scrapers used to
+func BenchmarkUpstreamClient(b *testing.B) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"totalCount":0,"results":[]}`))
+	}))
+	defer server.Close()
+
+	// Trust the test server's certificate
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	b.Run("shared", func(b *testing.B) {
+		client := newUpstreamClient(DefaultClientConfig, nil).SetTLSClientConfig(tlsConfig)
+		for b.Loop() {
+			if _, err := client.R().Get(server.URL); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("per-request", func(b *testing.B) {
+		for b.Loop() {
+			client := resty.New().SetTLSClientConfig(tlsConfig)
+			if _, err := client.R().Get(server.URL); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
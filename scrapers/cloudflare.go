@@ -0,0 +1,183 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrCloudflareChallenge means Cloudflare answered with a challenge or block
+// page instead of letting the request through to abc.virginia.gov
+var ErrCloudflareChallenge = fmt.Errorf("cloudflare challenge: %w", ErrUpstreamBlocked)
+
+const (
+	// cloudflareSiteURL is the page loaded in the browser to pass the challenge
+	cloudflareSiteURL = "https://www.abc.virginia.gov/"
+
+	// browserUserAgent is the user agent the browser presents, which the
+	// cf_clearance cookie is tied to
+	browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/132.0.0.0 Safari/537.36"
+
+	// cloudflareRefreshInterval stops concurrent callers that all hit the
+	// challenge from each starting a browser
+	cloudflareRefreshInterval = time.Minute
+)
+
+// CloudflareHandoff enables passing Cloudflare challenges in a headless
+// browser and retrying with the cf_clearance cookie it earns
+var CloudflareHandoff = false
+
+// CloudflareSolveTimeout bounds how long the browser may take to pass a challenge
+var CloudflareSolveTimeout = 60 * time.Second
+
+// cloudflareChallengeMarkers are strings found in Cloudflare challenge and block pages
+var cloudflareChallengeMarkers = []string{
+	"<title>Just a moment...</title>",
+	"<title>Attention Required! | Cloudflare</title>",
+	"cf-browser-verification",
+	"challenge-platform",
+	"cf_chl_opt",
+}
+
+// cloudflareClearance is the cookie set earned by passing a challenge
+type cloudflareClearance struct {
+	// solving lets one caller at a time run the browser; others wait for it
+	// until their own context is done
+	solving chan struct{}
+
+	mu         sync.Mutex
+	cookies    []*http.Cookie
+	userAgent  string
+	obtainedAt time.Time
+}
+
+var clearance = &cloudflareClearance{solving: make(chan struct{}, 1)}
+
+// isCloudflareChallenge reports whether a response is a Cloudflare challenge
+// or block page rather than an answer from the site
+func isCloudflareChallenge(resp *resty.Response) bool {
+	if resp == nil {
+		return false
+	}
+	if strings.EqualFold(resp.Header().Get("cf-mitigated"), "challenge") {
+		return true
+	}
+	if !strings.EqualFold(resp.Header().Get("Server"), "cloudflare") ||
+		!strings.Contains(resp.Header().Get("Content-Type"), "text/html") {
+		return false
+	}
+
+	body := resp.String()
+	for _, marker := range cloudflareChallengeMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply adds the clearance cookies and matching user agent to a request
+func (c *cloudflareClearance) apply(req *resty.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.cookies) == 0 {
+		return
+	}
+	req.SetCookies(c.cookies)
+	req.SetHeader("User-Agent", c.userAgent)
+}
+
+// refresh passes the challenge in a headless browser and stores the cookies
+// it earns, unless another caller did so within cloudflareRefreshInterval.
+// The solve is bounded by ctx as well as CloudflareSolveTimeout.
+func (c *cloudflareClearance) refresh(ctx context.Context) error {
+	select {
+	case c.solving <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.solving }()
+
+	c.mu.Lock()
+	recent := time.Since(c.obtainedAt) < cloudflareRefreshInterval
+	c.mu.Unlock()
+	if recent {
+		return nil
+	}
+
+	cookies, err := solveCloudflareChallenge(ctx, cloudflareSiteURL)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cookies = cookies
+	c.userAgent = browserUserAgent
+	c.obtainedAt = time.Now()
+	return nil
+}
+
+// solveCloudflareChallenge loads a page in a headless browser, waits for the
+// "Just a moment..." interstitial to clear and returns the site's cookies
+func solveCloudflareChallenge(ctx context.Context, pageURL string) ([]*http.Cookie, error) {
+	var browserCookies []*network.Cookie
+	err := runBrowser(ctx, CloudflareSolveTimeout,
+		chromedp.Navigate(pageURL),
+		waitForChallenge(),
+
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			browserCookies, err = network.GetCookies().WithURLs([]string{pageURL}).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pass cloudflare challenge: %w", err)
+	}
+
+	var cookies []*http.Cookie
+	found := false
+	for _, cookie := range browserCookies {
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		if cookie.Name == "cf_clearance" {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("browser passed the challenge page but got no cf_clearance cookie")
+	}
+
+	return cookies, nil
+}
+
+// handleCloudflare checks a response for a Cloudflare challenge. With
+// CloudflareHandoff it refreshes the clearance and reports that the request
+// should be retried; otherwise it returns ErrCloudflareChallenge.
+func handleCloudflare(ctx context.Context, resp *resty.Response, retried bool) (retry bool, err error) {
+	if !isCloudflareChallenge(resp) {
+		return false, nil
+	}
+	if !CloudflareHandoff || retried {
+		return false, fmt.Errorf("%s returned status %d: %w", resp.Request.URL, resp.StatusCode(), ErrCloudflareChallenge)
+	}
+
+	if err := clearance.refresh(ctx); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		log.Printf("cloudflare handoff failed: %v", err)
+		return false, fmt.Errorf("%s returned status %d: %w", resp.Request.URL, resp.StatusCode(), ErrCloudflareChallenge)
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClearanceCookiesReplaceTheSearchHeaders(t *testing.T) {
+	var cookies []*http.Cookie
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies = r.Cookies()
+	}))
+	defer server.Close()
+
+	c := &cloudflareClearance{
+		solving:   make(chan struct{}, 1),
+		cookies:   []*http.Cookie{{Name: "cf_clearance", Value: "fresh"}},
+		userAgent: browserUserAgent,
+	}
+	for name, headers := range map[string]map[string]string{
+		"products": productSearchHeaders("token"),
+		"stores":   storeSearchHeaders("token"),
+	} {
+		req := httpClient().R().SetHeaders(headers)
+		c.apply(req)
+		if _, err := req.Post(server.URL); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(cookies) != 1 || cookies[0].Name != "cf_clearance" || cookies[0].Value != "fresh" {
+			t.Errorf("%s: sent cookies %v, want only the refreshed cf_clearance", name, cookies)
+		}
+	}
+}
+
+func TestClearanceRefreshHonorsContext(t *testing.T) {
+	c := &cloudflareClearance{solving: make(chan struct{}, 1)}
+
+	// Another caller is running the browser
+	c.solving <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("refresh = %v, want the caller's deadline", err)
+	}
+}
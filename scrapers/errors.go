@@ -0,0 +1,79 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Errors the scrapers wrap their failures in, so callers can tell why a
+// scrape failed without parsing messages
+var (
+	// ErrTokenExpired means Coveo rejected the bearer token or it is past its expiry
+	ErrTokenExpired = errors.New("coveo bearer token expired or rejected")
+
+	// ErrGeocodeNotFound means the geocoder has no location for a zip code
+	ErrGeocodeNotFound = errors.New("zip code could not be geocoded")
+
+	// ErrProductNotFound means no product in the catalog has the requested SKU
+	ErrProductNotFound = errors.New("no product found for sku")
+
+	// ErrUpstreamBlocked means the upstream refused the request, such as a 403 or 429
+	ErrUpstreamBlocked = errors.New("upstream blocked the request")
+
+	// ErrUpstreamTimeout means the upstream did not answer in time
+	ErrUpstreamTimeout = errors.New("upstream request timed out")
+
+	// ErrUpstreamSchema means the upstream answered with something that could not be parsed
+	ErrUpstreamSchema = errors.New("upstream response was not in the expected format")
+
+	// ErrUpstreamFailed means the upstream could not be reached or returned an unexpected status
+	ErrUpstreamFailed = errors.New("upstream request failed")
+)
+
+// requestError classifies a failed request to a dependency. Errors from our
+// own limiter and circuit breakers, and cancellation by the caller, are
+// passed through as they are.
+func requestError(dependency string, err error) error {
+	var limited *RateLimitedError
+	var unavailable *UpstreamUnavailableError
+	if errors.As(err, &limited) || errors.As(err, &unavailable) || errors.Is(err, ErrCloudflareChallenge) || errors.Is(err, ErrNoProxyAvailable) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%s request timed out: %w: %w", dependency, ErrUpstreamTimeout, err)
+	}
+	return fmt.Errorf("failed to call %s: %w: %w", dependency, ErrUpstreamFailed, err)
+}
+
+// statusError classifies a non-200 response from a dependency. The response
+// body is logged rather than returned so it never reaches API clients.
+func statusError(dependency string, resp *resty.Response) error {
+	body := resp.String()
+	if len(body) > 500 {
+		body = body[:500]
+	}
+	log.Printf("%s returned status %d: %s", dependency, resp.StatusCode(), body)
+
+	switch resp.StatusCode() {
+	case http.StatusUnauthorized, 419:
+		return fmt.Errorf("%s returned status %d: %w", dependency, resp.StatusCode(), ErrTokenExpired)
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return fmt.Errorf("%s returned status %d: %w", dependency, resp.StatusCode(), ErrUpstreamBlocked)
+	case http.StatusGatewayTimeout:
+		return fmt.Errorf("%s returned status %d: %w", dependency, resp.StatusCode(), ErrUpstreamTimeout)
+	}
+	return fmt.Errorf("%s returned status %d: %w", dependency, resp.StatusCode(), ErrUpstreamFailed)
+}
+
+// schemaError wraps a failure to parse a dependency's response
+func schemaError(dependency string, err error) error {
+	return fmt.Errorf("failed to parse %s response: %w: %w", dependency, ErrUpstreamSchema, err)
+}
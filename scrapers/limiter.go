@@ -0,0 +1,165 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Priority orders callers waiting for the upstream limiter
+type Priority int
+
+const (
+	// PriorityInteractive is for requests a user of the API is waiting on
+	PriorityInteractive Priority = iota
+
+	// PriorityBackground is for crawls and syncs, which only run when no
+	// interactive caller is waiting
+	PriorityBackground
+)
+
+// RateLimitedError is returned when a call to abc.virginia.gov would have to
+// wait longer than its priority allows for the upstream limiter
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("upstream rate limit reached, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// upstreamLimiter is a token bucket combined with a cap on in-flight requests,
+// shared by every call the scrapers make to abc.virginia.gov
+type upstreamLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // tokens added per second
+	burst       float64
+	tokens      float64
+	refilledAt  time.Time
+	maxInFlight int
+	inFlight    int
+	maxWait     [2]time.Duration
+	waiting     [2]int
+
+	// changed is closed and replaced whenever a slot is released
+	changed chan struct{}
+}
+
+// upstream is replaced by ConfigureUpstreamLimit while requests may be acquiring it
+var (
+	upstreamMu sync.Mutex
+	upstream   = newUpstreamLimiter(5, 10, 4, 5*time.Second, 10*time.Minute)
+)
+
+func newUpstreamLimiter(rate float64, burst, maxInFlight int, interactiveWait, backgroundWait time.Duration) *upstreamLimiter {
+	burst = max(burst, 1)
+	maxInFlight = max(maxInFlight, 1)
+
+	return &upstreamLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		tokens:      float64(burst),
+		refilledAt:  time.Now(),
+		maxInFlight: maxInFlight,
+		maxWait:     [2]time.Duration{interactiveWait, backgroundWait},
+		changed:     make(chan struct{}),
+	}
+}
+
+// ConfigureUpstreamLimit replaces the upstream limiter. rate is requests per
+// second with bursts of up to burst, at most maxInFlight run at once, and an
+// interactive caller fails rather than wait longer than interactiveWait.
+func ConfigureUpstreamLimit(rate float64, burst, maxInFlight int, interactiveWait, backgroundWait time.Duration) {
+	limiter := newUpstreamLimiter(rate, burst, maxInFlight, interactiveWait, backgroundWait)
+
+	upstreamMu.Lock()
+	upstream = limiter
+	upstreamMu.Unlock()
+}
+
+// acquireUpstream waits for the shared upstream limiter and returns a
+// function that must be called once the request is done
+func acquireUpstream(ctx context.Context, priority Priority) (func(), error) {
+	upstreamMu.Lock()
+	limiter := upstream
+	upstreamMu.Unlock()
+
+	return limiter.acquire(ctx, priority)
+}
+
+func (l *upstreamLimiter) acquire(ctx context.Context, priority Priority) (func(), error) {
+	deadline := time.Now().Add(l.maxWait[priority])
+
+	l.mu.Lock()
+	l.waiting[priority]++
+	for {
+		now := time.Now()
+		l.refill(now)
+
+		ahead := l.waiting[PriorityInteractive]
+		if priority == PriorityBackground {
+			ahead += l.waiting[PriorityBackground]
+		}
+
+		// Background callers give way to any interactive caller still waiting
+		mayGo := priority == PriorityInteractive || l.waiting[PriorityInteractive] == 0
+		if mayGo && l.inFlight < l.maxInFlight && l.tokens >= 1 {
+			l.tokens--
+			l.inFlight++
+			l.waiting[priority]--
+			l.mu.Unlock()
+			return l.release, nil
+		}
+
+		// Time until enough tokens exist for everyone queued at or above this
+		// priority. Waiting on in-flight requests can't be predicted, so that
+		// is bounded by the deadline alone.
+		estimate := time.Duration((float64(ahead) - l.tokens) / l.rate * float64(time.Second))
+		if !now.Before(deadline) || now.Add(estimate).After(deadline) {
+			l.waiting[priority]--
+			l.mu.Unlock()
+			return nil, &RateLimitedError{RetryAfter: max(estimate, time.Second)}
+		}
+
+		// Sleep until the next token, a released slot or the deadline
+		wait := time.Until(deadline)
+		if l.tokens < 1 {
+			wait = min(wait, time.Duration((1-l.tokens)/l.rate*float64(time.Second)))
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.mu.Lock()
+			l.waiting[priority]--
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		timer.Stop()
+
+		l.mu.Lock()
+	}
+}
+
+// release frees an in-flight slot and wakes the waiting callers
+func (l *upstreamLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// refill adds the tokens earned since the last refill, up to burst
+func (l *upstreamLimiter) refill(now time.Time) {
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.refilledAt).Seconds()*l.rate)
+	l.refilledAt = now
+}
@@ -0,0 +1,31 @@
+package scrapers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race to check the limiter can be replaced while it is in use
+func TestConfigureUpstreamLimitWhileAcquiring(t *testing.T) {
+	t.Cleanup(func() { ConfigureUpstreamLimit(5, 10, 4, 5*time.Second, 10*time.Minute) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				release, err := acquireUpstream(context.Background(), PriorityInteractive)
+				if err == nil {
+					release()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		ConfigureUpstreamLimit(1000, 100, 4, time.Second, time.Second)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,330 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrNoProxyAvailable means every pooled proxy is unhealthy or was taken out
+// of rotation for being blocked
+var ErrNoProxyAvailable = fmt.Errorf("no upstream proxy available: %w", ErrUpstreamBlocked)
+
+// ProxyPoolConfig lists the proxies requests to abc.virginia.gov rotate through
+type ProxyPoolConfig struct {
+	// URLs are http://, https://, socks5:// or socks5h:// proxy URLs, with
+	// credentials in the user info if the proxy needs them
+	URLs []string
+	// HealthCheckURL is fetched through each proxy every HealthCheckInterval;
+	// proxies that cannot reach it are skipped until they can again
+	HealthCheckURL      string
+	HealthCheckInterval time.Duration
+	// MaxBlocks is how many block or challenge responses in a row take a
+	// proxy out of rotation. It comes back after BlockCooldown, or sooner if
+	// a health check gets through without being blocked.
+	MaxBlocks     int
+	BlockCooldown time.Duration
+}
+
+// DefaultProxyPoolConfig fills in settings ConfigureProxyPool is given as zero
+var DefaultProxyPoolConfig = ProxyPoolConfig{
+	HealthCheckURL:      cloudflareSiteURL,
+	HealthCheckInterval: 5 * time.Minute,
+	MaxBlocks:           3,
+	BlockCooldown:       30 * time.Minute,
+}
+
+// ProxyStatus is a snapshot of one pooled proxy
+type ProxyStatus struct {
+	Proxy   string `json:"proxy"`
+	Healthy bool   `json:"healthy"`
+	Blocks  int    `json:"consecutive_blocks"`
+	Removed bool   `json:"removed"`
+}
+
+// upstreamProxy is one proxy and the session that belongs to its address.
+// Its client keeps its own cookies, since the site ties them to the IP they
+// were issued to, its searches come from its own simulated visitor, and it
+// keeps sending the bearer token it started with.
+type upstreamProxy struct {
+	url       *url.URL
+	client    *resty.Client
+	visitor   *coveoVisitor
+	maxBlocks int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	token     bearerToken
+	healthy   bool
+	blocks    int
+	removed   bool
+	removedAt time.Time
+}
+
+// proxyPool hands out proxies round robin, skipping unhealthy and removed ones
+type proxyPool struct {
+	proxies []*upstreamProxy
+	stop    chan struct{}
+
+	mu   sync.Mutex
+	next int
+}
+
+// proxies is nil when requests go out directly
+var (
+	proxiesMu sync.Mutex
+	proxies   *proxyPool
+)
+
+// ConfigureProxyPool routes requests to abc.virginia.gov through the given
+// proxies, built on the current upstream client settings. An empty URL list
+// sends requests directly again.
+func ConfigureProxyPool(config ProxyPoolConfig) error {
+	if config.HealthCheckURL == "" {
+		config.HealthCheckURL = DefaultProxyPoolConfig.HealthCheckURL
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = DefaultProxyPoolConfig.HealthCheckInterval
+	}
+	if config.MaxBlocks <= 0 {
+		config.MaxBlocks = DefaultProxyPoolConfig.MaxBlocks
+	}
+	if config.BlockCooldown <= 0 {
+		config.BlockCooldown = DefaultProxyPoolConfig.BlockCooldown
+	}
+
+	var pool *proxyPool
+	if len(config.URLs) > 0 {
+		pool = &proxyPool{stop: make(chan struct{})}
+		clientConfig := currentClientConfig()
+		for _, raw := range config.URLs {
+			proxyURL, err := url.Parse(strings.TrimSpace(raw))
+			if err != nil {
+				return fmt.Errorf("invalid proxy %q: %w", raw, err)
+			}
+			switch proxyURL.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				return fmt.Errorf("unsupported proxy scheme %q in %s", proxyURL.Scheme, proxyURL.Redacted())
+			}
+
+			jar, err := cookiejar.New(nil)
+			if err != nil {
+				return err
+			}
+			pool.proxies = append(pool.proxies, &upstreamProxy{
+				url:       proxyURL,
+				client:    newUpstreamClient(clientConfig, proxyURL).SetCookieJar(jar),
+				visitor:   &coveoVisitor{},
+				maxBlocks: config.MaxBlocks,
+				cooldown:  config.BlockCooldown,
+				healthy:   true,
+			})
+		}
+	}
+
+	proxiesMu.Lock()
+	old := proxies
+	proxies = pool
+	proxiesMu.Unlock()
+
+	if old != nil {
+		close(old.stop)
+	}
+	if pool != nil {
+		go pool.checkHealth(config.HealthCheckURL, config.HealthCheckInterval)
+	}
+	return nil
+}
+
+// Proxies reports the state of each pooled proxy, or nil when none are configured
+func Proxies() []ProxyStatus {
+	proxiesMu.Lock()
+	pool := proxies
+	proxiesMu.Unlock()
+
+	if pool == nil {
+		return nil
+	}
+
+	statuses := make([]ProxyStatus, 0, len(pool.proxies))
+	for _, proxy := range pool.proxies {
+		proxy.mu.Lock()
+		statuses = append(statuses, ProxyStatus{
+			Proxy:   proxy.url.Redacted(),
+			Healthy: proxy.healthy,
+			Blocks:  proxy.blocks,
+			Removed: proxy.removed,
+		})
+		proxy.mu.Unlock()
+	}
+	return statuses
+}
+
+// pickProxy returns the next proxy in rotation, or nil when requests go out directly
+func pickProxy() (*upstreamProxy, error) {
+	proxiesMu.Lock()
+	pool := proxies
+	proxiesMu.Unlock()
+
+	if pool == nil {
+		return nil, nil
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for range pool.proxies {
+		proxy := pool.proxies[pool.next]
+		pool.next = (pool.next + 1) % len(pool.proxies)
+
+		if proxy.available() {
+			return proxy, nil
+		}
+	}
+	return nil, ErrNoProxyAvailable
+}
+
+// available reports whether the proxy is healthy and in rotation, putting a
+// removed proxy back once its cooldown has passed
+func (p *upstreamProxy) available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.removed && time.Since(p.removedAt) >= p.cooldown {
+		p.readmit("its cooldown passed")
+	}
+	return p.healthy && !p.removed
+}
+
+// readmit puts a removed proxy back into rotation. p.mu must be held.
+func (p *upstreamProxy) readmit(reason string) {
+	p.removed = false
+	p.blocks = 0
+	log.Printf("proxy %s is back in rotation, %s", p.url.Redacted(), reason)
+}
+
+// blocked records whether the site blocked or challenged a response that came
+// through the proxy, taking the proxy out of rotation after maxBlocks in a
+// row. A response rejecting the proxy's token drops it so the current one is
+// picked up.
+func (p *upstreamProxy) blocked(resp *resty.Response) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == 419 {
+		p.token = bearerToken{}
+	}
+
+	if !isBlockResponse(resp) {
+		p.blocks = 0
+		return false
+	}
+
+	p.blocks++
+	if p.blocks >= p.maxBlocks && !p.removed {
+		p.removed = true
+		p.removedAt = time.Now()
+		log.Printf("proxy %s blocked %d times in a row, taking it out of rotation for %s", p.url.Redacted(), p.blocks, p.cooldown)
+	}
+	return true
+}
+
+// pinToken makes a request carry the proxy's own bearer token in place of
+// the current one, so the site keeps seeing one token per address. The proxy
+// takes the current token the first time and again once its own expires or
+// is rejected.
+func (p *upstreamProxy) pinToken(req *resty.Request) *resty.Request {
+	current, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return req
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token.Token == "" || time.Now().After(p.token.ExpiresAt) {
+		expiresAt, ok := tokenExpiry(current)
+		if !ok {
+			expiresAt = time.Now().Add(TokenTTL)
+		}
+		p.token = bearerToken{Token: current, ExpiresAt: expiresAt}
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token.Token)
+	return req
+}
+
+// Helper function to check whether the site blocked or challenged a response
+func isBlockResponse(resp *resty.Response) bool {
+	return resp.StatusCode() == http.StatusForbidden ||
+		resp.StatusCode() == http.StatusTooManyRequests ||
+		isCloudflareChallenge(resp)
+}
+
+// checkHealth fetches url through every proxy each interval until the pool is replaced
+func (pool *proxyPool) checkHealth(url string, interval time.Duration) {
+	for {
+		for _, proxy := range pool.proxies {
+			proxy.checkHealth(url)
+		}
+
+		select {
+		case <-pool.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkHealth marks the proxy healthy if it can reach url at all, and puts a
+// removed proxy back into rotation if the site no longer blocks it. Checks go
+// through the upstream limiter at background priority and are skipped while
+// the limiter is busy or the site's circuit is open, since a failure then says
+// nothing about the proxy. Results are not recorded against the breaker, so a
+// dead proxy cannot open the circuit for the others.
+func (p *upstreamProxy) checkHealth(url string) {
+	if coveoBreaker.status().State != CircuitClosed {
+		return
+	}
+
+	release, err := acquireUpstream(context.Background(), PriorityBackground)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	resp, err := p.client.R().Head(url)
+	healthy := err == nil && resp.StatusCode() < http.StatusInternalServerError
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if healthy != p.healthy {
+		if healthy {
+			log.Printf("proxy %s is reachable again", p.url.Redacted())
+		} else {
+			log.Printf("proxy %s failed its health check: %v", p.url.Redacted(), healthError(resp, err))
+		}
+	}
+	p.healthy = healthy
+
+	if healthy && p.removed && !isBlockResponse(resp) {
+		p.readmit("its health check was not blocked")
+	}
+}
+
+// Helper function to describe a failed health check
+func healthError(resp *resty.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("status %d", resp.StatusCode())
+}
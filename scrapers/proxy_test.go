@@ -0,0 +1,251 @@
+package scrapers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// fakeProxy is an HTTP proxy that answers every request itself. It issues a
+// session cookie named after itself and records the cookie each request carried.
+func fakeProxy(t *testing.T, name string, sessions *[]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		if cookie, err := r.Cookie("session"); err == nil {
+			*sessions = append(*sessions, name+" got "+cookie.Value)
+		} else {
+			*sessions = append(*sessions, name+" got none")
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: name, Path: "/"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxiesKeepTheirOwnCookies(t *testing.T) {
+	var sessions []string
+	first := fakeProxy(t, "first", &sessions)
+	second := fakeProxy(t, "second", &sessions)
+
+	err := ConfigureProxyPool(ProxyPoolConfig{
+		URLs:                []string{first.URL, second.URL},
+		HealthCheckURL:      "http://www.abc.virginia.gov.test/",
+		HealthCheckInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProxyPool: %v", err)
+	}
+	t.Cleanup(func() { ConfigureProxyPool(ProxyPoolConfig{}) })
+
+	breaker := newCircuitBreaker("test", 5, time.Minute)
+	for range 4 {
+		_, err := callABC(context.Background(), breaker, PriorityInteractive, http.MethodPost,
+			"http://www.abc.virginia.gov.test/coveo/rest/search/v2",
+			func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+				return client.R().SetHeaders(productSearchHeaders("token"))
+			})
+		if err != nil {
+			t.Fatalf("callABC: %v", err)
+		}
+	}
+
+	want := []string{"first got none", "second got none", "first got first", "second got second"}
+	if len(sessions) != len(want) {
+		t.Fatalf("sessions = %q, want %q", sessions, want)
+	}
+	for i := range want {
+		if sessions[i] != want[i] {
+			t.Errorf("request %d: %s, want %s", i, sessions[i], want[i])
+		}
+	}
+}
+
+func TestProxyHealthCheck(t *testing.T) {
+	var sessions []string
+	up := fakeProxy(t, "up", &sessions)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// While the site's circuit is open a failed check says nothing about the
+	// proxy. Opening it first also keeps the pool's own first check from running.
+	coveoBreaker.mu.Lock()
+	coveoBreaker.state, coveoBreaker.openedAt = CircuitOpen, time.Now()
+	coveoBreaker.mu.Unlock()
+	t.Cleanup(func() {
+		coveoBreaker.mu.Lock()
+		coveoBreaker.state, coveoBreaker.failures = CircuitClosed, 0
+		coveoBreaker.mu.Unlock()
+	})
+
+	err := ConfigureProxyPool(ProxyPoolConfig{
+		URLs:                []string{up.URL, down.URL},
+		HealthCheckURL:      "http://www.abc.virginia.gov.test/",
+		HealthCheckInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProxyPool: %v", err)
+	}
+	t.Cleanup(func() { ConfigureProxyPool(ProxyPoolConfig{}) })
+
+	pool := proxies
+	healthy := func() []bool {
+		var states []bool
+		for _, proxy := range pool.proxies {
+			proxy.mu.Lock()
+			states = append(states, proxy.healthy)
+			proxy.mu.Unlock()
+		}
+		return states
+	}
+
+	pool.proxies[1].checkHealth("http://www.abc.virginia.gov.test/")
+	if got := healthy(); !got[1] {
+		t.Error("checked the proxy while the circuit was open")
+	}
+
+	coveoBreaker.mu.Lock()
+	coveoBreaker.state = CircuitClosed
+	coveoBreaker.mu.Unlock()
+	for _, proxy := range pool.proxies {
+		proxy.checkHealth("http://www.abc.virginia.gov.test/")
+	}
+	if got := healthy(); !got[0] || got[1] {
+		t.Errorf("healthy = %v, want [true false]", got)
+	}
+
+	if proxy, err := pickProxy(); err != nil || proxy != pool.proxies[0] {
+		t.Errorf("pickProxy = %v, %v, want the healthy proxy", proxy, err)
+	}
+}
+
+func TestRemovedProxyComesBack(t *testing.T) {
+	var sessions []string
+	open := fakeProxy(t, "open", &sessions)
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(blocking.Close)
+
+	// Opening the circuit keeps the pool's own first check from running
+	coveoBreaker.mu.Lock()
+	coveoBreaker.state, coveoBreaker.openedAt = CircuitOpen, time.Now()
+	coveoBreaker.mu.Unlock()
+	t.Cleanup(func() {
+		coveoBreaker.mu.Lock()
+		coveoBreaker.state, coveoBreaker.failures = CircuitClosed, 0
+		coveoBreaker.mu.Unlock()
+	})
+
+	err := ConfigureProxyPool(ProxyPoolConfig{
+		URLs:                []string{open.URL, blocking.URL},
+		HealthCheckURL:      "http://www.abc.virginia.gov.test/",
+		HealthCheckInterval: time.Hour,
+		BlockCooldown:       time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProxyPool: %v", err)
+	}
+	t.Cleanup(func() { ConfigureProxyPool(ProxyPoolConfig{}) })
+
+	pool := proxies
+	for _, proxy := range pool.proxies {
+		proxy.mu.Lock()
+		proxy.removed, proxy.removedAt = true, time.Now()
+		proxy.mu.Unlock()
+	}
+	if _, err := pickProxy(); err != ErrNoProxyAvailable {
+		t.Fatalf("pickProxy with every proxy removed = %v, want ErrNoProxyAvailable", err)
+	}
+
+	// A health check that gets through unblocked re-admits the proxy early
+	coveoBreaker.mu.Lock()
+	coveoBreaker.state = CircuitClosed
+	coveoBreaker.mu.Unlock()
+	for _, proxy := range pool.proxies {
+		proxy.checkHealth("http://www.abc.virginia.gov.test/")
+	}
+	if !pool.proxies[0].available() {
+		t.Error("proxy that passed its health check is still out of rotation")
+	}
+	if pool.proxies[1].available() {
+		t.Error("proxy that is still blocked was re-admitted")
+	}
+
+	// Otherwise it comes back once the cooldown has passed
+	blocked := pool.proxies[1]
+	blocked.mu.Lock()
+	blocked.removedAt = time.Now().Add(-2 * time.Minute)
+	blocked.blocks = 3
+	blocked.mu.Unlock()
+	if !blocked.available() {
+		t.Fatal("proxy still out of rotation after its cooldown")
+	}
+	if blocked.blocks != 0 {
+		t.Errorf("blocks = %d after re-admission, want 0", blocked.blocks)
+	}
+}
+
+func TestProxyKeepsItsToken(t *testing.T) {
+	var (
+		tokens []string
+		status = http.StatusOK
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	err := ConfigureProxyPool(ProxyPoolConfig{
+		URLs:                []string{server.URL},
+		HealthCheckURL:      "http://www.abc.virginia.gov.test/",
+		HealthCheckInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureProxyPool: %v", err)
+	}
+	t.Cleanup(func() { ConfigureProxyPool(ProxyPoolConfig{}) })
+
+	breaker := newCircuitBreaker("test", 5, time.Minute)
+	search := func(token string) {
+		t.Helper()
+		_, err := callABC(context.Background(), breaker, PriorityInteractive, http.MethodPost,
+			"http://www.abc.virginia.gov.test/coveo/rest/search/v2",
+			func(client *resty.Client, visitor *visitorRequest) *resty.Request {
+				return client.R().SetHeaders(productSearchHeaders(token))
+			})
+		if err != nil {
+			t.Fatalf("callABC: %v", err)
+		}
+	}
+
+	search("first")
+	search("second")
+
+	// Once Coveo rejects the pinned token the proxy takes the current one
+	status = http.StatusUnauthorized
+	search("second")
+	status = http.StatusOK
+	search("second")
+
+	want := []string{"Bearer first", "Bearer first", "Bearer first", "Bearer second"}
+	if len(tokens) != len(want) {
+		t.Fatalf("tokens = %q, want %q", tokens, want)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("request %d sent %q, want %q", i, tokens[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package scrapers
+
+import (
+	"log"
+	"time"
+)
+
+// Kinds of scrape runs reported to the Recorder
+const (
+	RunProductSearch  = "product_search"
+	RunStoreSearch    = "store_search"
+	RunStoreDirectory = "store_directory"
+	RunCatalogCrawl   = "catalog_crawl"
+	RunSales          = "sales"
+)
+
+// ScrapeRun describes one call out to the ABC site
+type ScrapeRun struct {
+	Kind       string    `json:"kind"`
+	Query      string    `json:"query,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Count      int       `json:"count"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Recorder receives the results of every scrape so they can be persisted.
+// The products or stores are nil when the run failed.
+type Recorder interface {
+	RecordProducts(run ScrapeRun, products []ProductResult) error
+	RecordStores(run ScrapeRun, stores []StoreResult) error
+}
+
+var recorder Recorder
+
+// SetRecorder sets where scrape results are written. A nil Recorder disables recording.
+func SetRecorder(r Recorder) {
+	recorder = r
+}
+
+// newRun starts a ScrapeRun of the given kind
+func newRun(kind, query string) ScrapeRun {
+	return ScrapeRun{Kind: kind, Query: query, StartedAt: time.Now()}
+}
+
+// recordProducts finishes a run and hands its products to the Recorder
+func recordProducts(run ScrapeRun, products []ProductResult, err error) {
+	if recorder == nil {
+		return
+	}
+
+	run.FinishedAt = time.Now()
+	run.Count = len(products)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if err := recorder.RecordProducts(run, products); err != nil {
+		log.Printf("Failed to record %s run: %v", run.Kind, err)
+	}
+}
+
+// recordStores finishes a run and hands its stores to the Recorder
+func recordStores(run ScrapeRun, stores []StoreResult, err error) {
+	if recorder == nil {
+		return
+	}
+
+	run.FinishedAt = time.Now()
+	run.Count = len(stores)
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	if err := recorder.RecordStores(run, stores); err != nil {
+		log.Printf("Failed to record %s run: %v", run.Kind, err)
+	}
+}
@@ -0,0 +1,165 @@
+package scrapers
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// earthRadiusMeters is the mean Earth radius used for haversine distances
+	earthRadiusMeters = 6371000.0
+
+	// metersPerDegree is the length of one degree of latitude
+	metersPerDegree = earthRadiusMeters * math.Pi / 180
+
+	// storeIndexCellDegrees is the size of a grid cell in the store index
+	storeIndexCellDegrees = 0.25
+)
+
+// storeIndex is a fixed grid over store coordinates used to answer
+// nearest-store queries without calling Coveo
+type storeIndex struct {
+	stores    []StoreResult
+	cells     map[[2]int][]int
+	minCell   [2]int
+	maxCell   [2]int
+	maxAbsLat float64
+}
+
+// newStoreIndex buckets stores into grid cells by latitude/longitude.
+// Stores without coordinates are left out of the index.
+func newStoreIndex(stores []StoreResult) *storeIndex {
+	idx := &storeIndex{
+		stores: stores,
+		cells:  make(map[[2]int][]int),
+	}
+
+	first := true
+	for i, store := range stores {
+		if store.Latitude == 0 && store.Longitude == 0 {
+			continue
+		}
+
+		cell := cellFor(store.Latitude, store.Longitude)
+		idx.cells[cell] = append(idx.cells[cell], i)
+
+		if first {
+			idx.minCell, idx.maxCell = cell, cell
+			first = false
+		}
+		for axis := 0; axis < 2; axis++ {
+			idx.minCell[axis] = min(idx.minCell[axis], cell[axis])
+			idx.maxCell[axis] = max(idx.maxCell[axis], cell[axis])
+		}
+		idx.maxAbsLat = math.Max(idx.maxAbsLat, math.Abs(store.Latitude))
+	}
+
+	return idx
+}
+
+// nearest returns up to k stores closest to the given point, ordered by
+// distance, with Distance filled in as meters like the Coveo dist() function
+func (idx *storeIndex) nearest(lat, lng float64, k int) []StoreResult {
+	if len(idx.cells) == 0 || k <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		store  int
+		meters float64
+	}
+
+	center := cellFor(lat, lng)
+
+	// A cell r rings away is at least r-1 cells away on one axis. Longitude
+	// degrees shrink toward the poles, so bound with the widest latitude seen.
+	cosLat := math.Cos(math.Max(idx.maxAbsLat, math.Abs(lat)+storeIndexCellDegrees) * math.Pi / 180)
+	ringMeters := storeIndexCellDegrees * metersPerDegree * cosLat
+
+	maxRing := 0
+	for axis := 0; axis < 2; axis++ {
+		maxRing = max(maxRing, abs(center[axis]-idx.minCell[axis]), abs(idx.maxCell[axis]-center[axis]))
+	}
+
+	var candidates []candidate
+	for ring := 0; ring <= maxRing; ring++ {
+		for dy := -ring; dy <= ring; dy++ {
+			for dx := -ring; dx <= ring; dx++ {
+				// Only visit the outer edge of the ring
+				if abs(dx) != ring && abs(dy) != ring {
+					continue
+				}
+				for _, i := range idx.cells[[2]int{center[0] + dy, center[1] + dx}] {
+					store := idx.stores[i]
+					candidates = append(candidates, candidate{
+						store:  i,
+						meters: haversineMeters(lat, lng, store.Latitude, store.Longitude),
+					})
+				}
+			}
+		}
+
+		// Stop once no unvisited cell can beat the current k-th candidate
+		if len(candidates) >= k {
+			sort.Slice(candidates, func(a, b int) bool {
+				return candidates[a].meters < candidates[b].meters
+			})
+			if candidates[k-1].meters <= float64(ring)*ringMeters {
+				break
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].meters < candidates[b].meters
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	results := make([]StoreResult, 0, len(candidates))
+	for _, c := range candidates {
+		store := idx.stores[c.store]
+		store.Distance = distanceField(c.meters)
+		store.Source = StoreSourceDirectory
+		results = append(results, store)
+	}
+
+	return results
+}
+
+// cellFor returns the grid cell (latitude row, longitude column) of a point
+func cellFor(lat, lng float64) [2]int {
+	return [2]int{
+		int(math.Floor(lat / storeIndexCellDegrees)),
+		int(math.Floor(lng / storeIndexCellDegrees)),
+	}
+}
+
+// haversineMeters returns the great-circle distance between two points in meters
+func haversineMeters(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLng := (lng2 - lng1) * toRad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// distanceField converts a distance to the int16 used by StoreResult,
+// clamping instead of overflowing for far away stores
+func distanceField(meters float64) int16 {
+	if meters >= math.MaxInt16 {
+		return math.MaxInt16
+	}
+	return int16(meters)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
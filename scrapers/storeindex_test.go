@@ -0,0 +1,146 @@
+package scrapers
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// Helper function to list store titles in order
+func storeTitles(stores []StoreResult) []string {
+	titles := make([]string, len(stores))
+	for i, store := range stores {
+		titles[i] = store.Title
+	}
+	return titles
+}
+
+// bruteForceNearest sorts every store with coordinates by haversine distance
+func bruteForceNearest(stores []StoreResult, lat, lng float64, k int) []string {
+	var located []StoreResult
+	for _, store := range stores {
+		if store.Latitude != 0 || store.Longitude != 0 {
+			located = append(located, store)
+		}
+	}
+	sort.Slice(located, func(a, b int) bool {
+		return haversineMeters(lat, lng, located[a].Latitude, located[a].Longitude) <
+			haversineMeters(lat, lng, located[b].Latitude, located[b].Longitude)
+	})
+	return storeTitles(located[:min(k, len(located))])
+}
+
+func TestStoreIndexMatchesBruteForce(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	// Scattered over Virginia, with a cluster in one cell so some queries
+	// fill k from the center cell alone
+	var stores []StoreResult
+	for i := 0; i < 300; i++ {
+		stores = append(stores, StoreResult{
+			Title:     fmt.Sprintf("store %d", i),
+			Latitude:  36.5 + random.Float64()*3,
+			Longitude: -83.5 + random.Float64()*8,
+		})
+	}
+	for i := 0; i < 20; i++ {
+		stores = append(stores, StoreResult{
+			Title:     fmt.Sprintf("richmond %d", i),
+			Latitude:  37.5 + random.Float64()*0.2,
+			Longitude: -77.5 + random.Float64()*0.2,
+		})
+	}
+	idx := newStoreIndex(stores)
+
+	for q := 0; q < 200; q++ {
+		lat := 36 + random.Float64()*4
+		lng := -84 + random.Float64()*9
+		for _, k := range []int{1, 5, 20} {
+			got := storeTitles(idx.nearest(lat, lng, k))
+			want := bruteForceNearest(stores, lat, lng, k)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("nearest(%f, %f, %d) = %v, want %v", lat, lng, k, got, want)
+			}
+		}
+	}
+}
+
+func TestStoreIndexSearchesPastCellBoundaries(t *testing.T) {
+	// The query sits in the north-east corner of its cell. The store in the
+	// same cell is in the opposite corner, about 35km away, while one just
+	// across the east boundary is 1km away and one two cells east is 22km away.
+	lat, lng := 37.249, -77.001
+	stores := []StoreResult{
+		{Title: "same cell", Latitude: 37.001, Longitude: -77.249},
+		{Title: "next cell", Latitude: 37.249, Longitude: -76.99},
+		{Title: "two rings", Latitude: 37.249, Longitude: -76.749},
+	}
+	idx := newStoreIndex(stores)
+
+	if got := storeTitles(idx.nearest(lat, lng, 1)); fmt.Sprint(got) != "[next cell]" {
+		t.Errorf("nearest k=1 = %v, want the store across the boundary", got)
+	}
+
+	// After ring 1 the second candidate is the same-cell store, further than
+	// the 22km ring 1 rules out, so the search must go on to ring 2
+	got := storeTitles(idx.nearest(lat, lng, 2))
+	if fmt.Sprint(got) != "[next cell two rings]" {
+		t.Errorf("nearest k=2 = %v, want [next cell two rings]", got)
+	}
+	if want := bruteForceNearest(stores, lat, lng, 2); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("nearest k=2 = %v, brute force %v", got, want)
+	}
+}
+
+func TestStoreIndexReturnsEveryStoreWhenKIsLarger(t *testing.T) {
+	stores := []StoreResult{
+		{Title: "Broad St", Latitude: 37.55, Longitude: -77.46},
+		{Title: "no coordinates"},
+		{Title: "Norfolk", Latitude: 36.85, Longitude: -76.29},
+		{Title: "Roanoke", Latitude: 37.27, Longitude: -79.94},
+	}
+	idx := newStoreIndex(stores)
+
+	results := idx.nearest(37.54, -77.43, 10)
+	if got := storeTitles(results); fmt.Sprint(got) != "[Broad St Norfolk Roanoke]" {
+		t.Fatalf("nearest = %v, want every located store by distance", got)
+	}
+	for _, store := range results {
+		if store.Source != StoreSourceDirectory {
+			t.Errorf("%s: Source = %q, want %q", store.Title, store.Source, StoreSourceDirectory)
+		}
+	}
+	if results[0].Distance <= 0 || results[0].Distance >= math.MaxInt16 {
+		t.Errorf("Broad St distance = %d, want meters within range", results[0].Distance)
+	}
+	if results[2].Distance != math.MaxInt16 {
+		t.Errorf("Roanoke distance = %d, want it clamped", results[2].Distance)
+	}
+
+	if got := newStoreIndex(nil).nearest(37.54, -77.43, 3); got != nil {
+		t.Errorf("empty index returned %v", got)
+	}
+	if got := idx.nearest(37.54, -77.43, 0); got != nil {
+		t.Errorf("k=0 returned %v", got)
+	}
+}
+
+func TestDistanceField(t *testing.T) {
+	tests := []struct {
+		meters float64
+		want   int16
+	}{
+		{0, 0},
+		{1234.7, 1234},
+		{math.MaxInt16 - 1, math.MaxInt16 - 1},
+		{math.MaxInt16, math.MaxInt16},
+		{250000, math.MaxInt16},
+	}
+	for _, tt := range tests {
+		if got := distanceField(tt.meters); got != tt.want {
+			t.Errorf("distanceField(%v) = %d, want %d", tt.meters, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package scrapers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"ABCScraper/cache"
+)
+
+// tokenFile is the file the token getter writes the current Coveo bearer token to
+const tokenFile = "uptodatetoken.txt"
+
+// tokenCacheKey is the key the current token is shared under in the token cache
+const tokenCacheKey = "coveo"
+
+// TokenTTL is how long a token is assumed to stay valid after the token file
+// was written, for tokens that don't carry their own expiry
+var TokenTTL = 12 * time.Hour
+
+// tokenBackend shares the current token between replicas, so ones without a
+// token getter of their own can use the token another replica fetched
+var tokenBackend cache.Backend = cache.NewMemory(1)
+
+// bearerToken is a Coveo bearer token and when it stops working
+type bearerToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ConfigureTokenCache shares the current token through a backend from factory
+func ConfigureTokenCache(factory cache.Factory) {
+	tokenBackend = factory("token", 1)
+}
+
+// readToken returns the current Coveo bearer token. The token file wins when it
+// holds a live token, which is then shared; otherwise the shared token is used.
+func readToken() (string, error) {
+	shared, haveShared := sharedToken()
+
+	current, err := readTokenFile()
+	if err != nil {
+		if haveShared {
+			return shared.Token, nil
+		}
+		return "", err
+	}
+
+	if haveShared && (shared.Token == current.Token || time.Now().After(current.ExpiresAt)) {
+		return shared.Token, nil
+	}
+
+	// Only a token's own exp claim is trusted to reject it; the TokenTTL
+	// estimate just limits how long it is shared
+	if expiresAt, ok := tokenExpiry(current.Token); ok && time.Now().After(expiresAt) {
+		return "", fmt.Errorf("token in %s expired at %s: %w", tokenFile, expiresAt.Format(time.RFC3339), ErrTokenExpired)
+	}
+
+	shareToken(current)
+	return current.Token, nil
+}
+
+// readTokenFile reads the Coveo bearer token from the first line of tokenFile
+func readTokenFile() (bearerToken, error) {
+	tokenBytes, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return bearerToken{}, fmt.Errorf("failed to read token from %s: %w", tokenFile, err)
+	}
+
+	// Split by newlines and take only the first line (the token)
+	lines := strings.Split(string(tokenBytes), "\n")
+	token := strings.TrimSpace(lines[0])
+	if token == "" {
+		return bearerToken{}, fmt.Errorf("token file %s is empty", tokenFile)
+	}
+
+	expiresAt, ok := tokenExpiry(token)
+	if !ok {
+		expiresAt = time.Now().Add(TokenTTL)
+		if info, err := os.Stat(tokenFile); err == nil {
+			expiresAt = info.ModTime().Add(TokenTTL)
+		}
+	}
+
+	return bearerToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// tokenExpiry reads the exp claim of a JWT bearer token
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// sharedToken returns the token in the token cache if it has not expired
+func sharedToken() (bearerToken, bool) {
+	entry, ok, err := tokenBackend.Get(tokenCacheKey)
+	if err != nil {
+		log.Printf("token cache: %v", err)
+		return bearerToken{}, false
+	}
+	if !ok {
+		return bearerToken{}, false
+	}
+
+	var token bearerToken
+	if err := json.Unmarshal(entry.Value, &token); err != nil || time.Now().After(token.ExpiresAt) {
+		return bearerToken{}, false
+	}
+	return token, true
+}
+
+// shareToken stores a live token in the token cache until it expires
+func shareToken(token bearerToken) {
+	keep := time.Until(token.ExpiresAt)
+	if keep <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(token)
+	if err != nil {
+		return
+	}
+	if err := tokenBackend.Set(tokenCacheKey, cache.Entry{Value: data, StoredAt: time.Now()}, keep); err != nil {
+		log.Printf("token cache: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package scrapers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"ABCScraper/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// useSharedTokenCache points the token cache at a fresh in-process Redis and
+// runs the test from an empty directory, so no token file exists until written
+func useSharedTokenCache(t *testing.T) redis.UniversalClient {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	previous := tokenBackend
+	ConfigureTokenCache(cache.RedisFactory(client, "abc:"))
+	t.Cleanup(func() { tokenBackend = previous })
+
+	t.Chdir(t.TempDir())
+	return client
+}
+
+// testJWT builds an unsigned JWT whose exp claim is expiresAt
+func testJWT(t *testing.T, expiresAt time.Time) string {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]int64{"exp": expiresAt.Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func writeTokenFile(t *testing.T, token string) {
+	t.Helper()
+	if err := os.WriteFile(tokenFile, []byte(token+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadTokenSharesTheFileToken(t *testing.T) {
+	client := useSharedTokenCache(t)
+	token := testJWT(t, time.Now().Add(time.Hour))
+	writeTokenFile(t, token)
+
+	got, err := readToken()
+	if err != nil || got != token {
+		t.Fatalf("readToken = %q, %v, want the file token", got, err)
+	}
+
+	// A replica with no token file of its own reads it from Redis
+	os.Remove(tokenFile)
+	ConfigureTokenCache(cache.RedisFactory(client, "abc:"))
+	shared, ok := sharedToken()
+	if !ok || shared.Token != token {
+		t.Fatalf("sharedToken = %+v, %v, want the shared file token", shared, ok)
+	}
+	if got, err := readToken(); err != nil || got != token {
+		t.Errorf("readToken without a token file = %q, %v, want the shared token", got, err)
+	}
+}
+
+func TestReadTokenPrefersALiveFileToken(t *testing.T) {
+	useSharedTokenCache(t)
+	shareToken(bearerToken{Token: "old", ExpiresAt: time.Now().Add(time.Hour)})
+
+	token := testJWT(t, time.Now().Add(2*time.Hour))
+	writeTokenFile(t, token)
+
+	if got, err := readToken(); err != nil || got != token {
+		t.Fatalf("readToken = %q, %v, want the newer file token", got, err)
+	}
+	if shared, _ := sharedToken(); shared.Token != token {
+		t.Errorf("shared token = %q, want the file token to replace it", shared.Token)
+	}
+}
+
+func TestReadTokenFallsBackFromAnExpiredFileToken(t *testing.T) {
+	useSharedTokenCache(t)
+	shareToken(bearerToken{Token: "shared", ExpiresAt: time.Now().Add(time.Hour)})
+	writeTokenFile(t, testJWT(t, time.Now().Add(-time.Minute)))
+
+	if got, err := readToken(); err != nil || got != "shared" {
+		t.Errorf("readToken = %q, %v, want the shared token", got, err)
+	}
+}
+
+func TestReadTokenRejectsAnExpiredToken(t *testing.T) {
+	useSharedTokenCache(t)
+	writeTokenFile(t, testJWT(t, time.Now().Add(-time.Minute)))
+
+	if _, err := readToken(); err == nil {
+		t.Error("readToken accepted an expired token with nothing shared")
+	}
+	if _, ok := sharedToken(); ok {
+		t.Error("an expired token was shared")
+	}
+}
+
+func TestSharedTokenIgnoresExpiredEntries(t *testing.T) {
+	useSharedTokenCache(t)
+	data, _ := json.Marshal(bearerToken{Token: "stale", ExpiresAt: time.Now().Add(-time.Minute)})
+	tokenBackend.Set(tokenCacheKey, cache.Entry{Value: data, StoredAt: time.Now()}, time.Hour)
+
+	if token, ok := sharedToken(); ok {
+		t.Errorf("sharedToken = %+v, want an expired token to be ignored", token)
+	}
+}
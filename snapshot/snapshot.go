@@ -0,0 +1,221 @@
+// Package snapshot builds versioned, gzip-compressed catalog files that the
+// mobile app downloads for offline use, and prunes old ones.
+package snapshot
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ABCScraper/scrapers"
+)
+
+// ErrNoSnapshot is returned when no snapshot has been built yet
+var ErrNoSnapshot = errors.New("no catalog snapshot has been built yet")
+
+// Catalog is the content of a snapshot file
+type Catalog struct {
+	Version     int64                    `json:"version"`
+	GeneratedAt time.Time                `json:"generated_at"`
+	Categories  []string                 `json:"categories"`
+	Products    []scrapers.ProductResult `json:"products"`
+}
+
+// Info describes a snapshot file on disk
+type Info struct {
+	Version int64
+	ETag    string
+	Path    string
+	Size    int64
+}
+
+// Store keeps snapshot files in a directory
+type Store struct {
+	dir    string
+	keep   int
+	maxAge time.Duration
+	mu     sync.Mutex
+}
+
+// NewStore creates a Store in dir. Pruning always keeps the newest keep
+// snapshots and removes older ones once they are older than maxAge.
+func NewStore(dir string, keep int, maxAge time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory %s: %w", dir, err)
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	return &Store{dir: dir, keep: keep, maxAge: maxAge}, nil
+}
+
+// Build writes a new snapshot of products unless it would be identical to the
+// latest one, then prunes old snapshots. It returns the latest snapshot either way.
+func (s *Store) Build(products []scrapers.ProductResult) (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sorted := make([]scrapers.ProductResult, len(products))
+	copy(sorted, products)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Title < sorted[j].Title })
+
+	// The ETag only covers the products so an unchanged catalog keeps its ETag
+	content, err := json.Marshal(sorted)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	etag := hex.EncodeToString(sum[:16])
+
+	now := time.Now().UTC()
+	version := now.Unix()
+	if latest, err := s.latest(); err == nil {
+		if latest.ETag == etag {
+			return latest, nil
+		}
+		// Versions must keep increasing even when two builds land in the same second
+		version = max(version, latest.Version+1)
+	}
+
+	catalog := Catalog{
+		Version:     version,
+		GeneratedAt: now,
+		Categories:  categories(sorted),
+		Products:    sorted,
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("catalog-%d-%s.json.gz", catalog.Version, etag))
+	if err := writeGzipJSON(path, catalog); err != nil {
+		return nil, err
+	}
+
+	if err := s.prune(); err != nil {
+		return nil, err
+	}
+
+	return s.latest()
+}
+
+// Latest returns the newest snapshot
+func (s *Store) Latest() (*Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.latest()
+}
+
+func (s *Store) latest() (*Info, error) {
+	snapshots, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, ErrNoSnapshot
+	}
+	return &snapshots[0], nil
+}
+
+// list returns every snapshot in the directory, newest first
+func (s *Store) list() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snapshots []Info
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "catalog-") || !strings.HasSuffix(name, ".json.gz") {
+			continue
+		}
+
+		// catalog-<version>-<etag>.json.gz
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, "catalog-"), ".json.gz"), "-")
+		if len(parts) != 2 {
+			continue
+		}
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, Info{
+			Version: version,
+			ETag:    parts[1],
+			Path:    filepath.Join(s.dir, name),
+			Size:    info.Size(),
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Version > snapshots[j].Version })
+	return snapshots, nil
+}
+
+// prune removes snapshots beyond the newest keep that are older than maxAge
+func (s *Store) prune() error {
+	snapshots, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-s.maxAge).Unix()
+	for i, snapshot := range snapshots {
+		if i < s.keep || snapshot.Version > cutoff {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil {
+			return fmt.Errorf("failed to remove snapshot %s: %w", snapshot.Path, err)
+		}
+	}
+	return nil
+}
+
+// categories returns the distinct product categories, sorted
+func categories(products []scrapers.ProductResult) []string {
+	seen := make(map[string]bool)
+	list := []string{}
+	for _, product := range products {
+		if product.Category != "" && !seen[product.Category] {
+			seen[product.Category] = true
+			list = append(list, product.Category)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// writeGzipJSON writes v as gzip-compressed JSON, renaming into place so
+// readers never see a partial file
+func writeGzipJSON(path string, v interface{}) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+	encodeErr := json.NewEncoder(gz).Encode(v)
+	closeErr := gz.Close()
+	fileErr := file.Close()
+
+	if err := errors.Join(encodeErr, closeErr, fileErr); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return os.Rename(tmp, path)
+}
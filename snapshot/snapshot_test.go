@@ -0,0 +1,139 @@
+package snapshot
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ABCScraper/scrapers"
+)
+
+// Helper function to decode a snapshot file
+func readCatalog(t *testing.T, path string) Catalog {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening snapshot: %v", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("reading snapshot: %v", err)
+	}
+	var catalog Catalog
+	if err := json.NewDecoder(gz).Decode(&catalog); err != nil {
+		t.Fatalf("decoding snapshot: %v", err)
+	}
+	return catalog
+}
+
+// Helper function to count the snapshot files in a directory
+func snapshotCount(t *testing.T, dir string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "catalog-*.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
+
+func TestBuildVersionsSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, 5, time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	if _, err := store.Latest(); !errors.Is(err, ErrNoSnapshot) {
+		t.Fatalf("Latest on an empty store = %v, want ErrNoSnapshot", err)
+	}
+
+	products := []scrapers.ProductResult{
+		{Title: "Tito's Handmade Vodka", ProductID: "010807", Category: "Vodka"},
+		{Title: "Buffalo Trace", ProductID: "018006", Category: "Bourbon"},
+	}
+	first, err := store.Build(products)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	catalog := readCatalog(t, first.Path)
+	if catalog.Version != first.Version || len(catalog.Products) != 2 || catalog.Products[0].Title != "Buffalo Trace" {
+		t.Errorf("catalog = %+v, want both products sorted by title", catalog)
+	}
+	if fmt.Sprint(catalog.Categories) != "[Bourbon Vodka]" {
+		t.Errorf("categories = %v", catalog.Categories)
+	}
+
+	// The same products in another order are not rebuilt
+	again, err := store.Build([]scrapers.ProductResult{products[1], products[0]})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if *again != *first || snapshotCount(t, dir) != 1 {
+		t.Errorf("unchanged build = %+v with %d files, want %+v alone", again, snapshotCount(t, dir), first)
+	}
+
+	// A change in the same second still gets a newer version and a new ETag
+	products[0].OnSale = true
+	second, err := store.Build(products)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if second.Version <= first.Version || second.ETag == first.ETag {
+		t.Errorf("changed build = %+v, want a newer version and ETag than %+v", second, first)
+	}
+	if latest, _ := store.Latest(); *latest != *second {
+		t.Errorf("Latest = %+v, want %+v", latest, second)
+	}
+}
+
+func TestBuildPrunesOldSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, 1, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	// Three snapshots from last week and one from an hour ago
+	now := time.Now()
+	versions := []int64{
+		now.Add(-9 * 24 * time.Hour).Unix(),
+		now.Add(-8 * 24 * time.Hour).Unix(),
+		now.Add(-7 * 24 * time.Hour).Unix(),
+		now.Add(-time.Hour).Unix(),
+	}
+	for i, version := range versions {
+		path := filepath.Join(dir, fmt.Sprintf("catalog-%d-etag%d.json.gz", version, i))
+		if err := writeGzipJSON(path, Catalog{Version: version}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	latest, err := store.Build([]scrapers.ProductResult{{Title: "Buffalo Trace"}})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	// The new snapshot is always kept, the hour-old one is within maxAge and
+	// the rest are removed
+	snapshots, err := store.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []int64
+	for _, snapshot := range snapshots {
+		kept = append(kept, snapshot.Version)
+	}
+	want := []int64{latest.Version, versions[3]}
+	if fmt.Sprint(kept) != fmt.Sprint(want) {
+		t.Errorf("kept versions %v, want %v", kept, want)
+	}
+}
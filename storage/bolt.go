@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ABCScraper/scrapers"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltRepository is a Repository backed by an embedded bbolt database file
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database at path and applies any pending migrations
+func Open(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltRepository{db: db}, nil
+}
+
+// Close closes the database file
+func (r *BoltRepository) Close() error {
+	return r.db.Close()
+}
+
+// RecordProducts stores a scrape run and upserts the products and variants it returned
+func (r *BoltRepository) RecordProducts(run scrapers.ScrapeRun, products []scrapers.ProductResult) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		if err := putRun(tx, run); err != nil {
+			return err
+		}
+
+		productBucket := tx.Bucket(productsBucket)
+		variantBucket := tx.Bucket(variantsBucket)
+		seen := run.FinishedAt
+
+		for _, product := range products {
+			key := productKey(product)
+			if key == "" {
+				continue
+			}
+
+			record := ProductRecord{Key: key, Title: product.Title, Product: product, FirstSeen: seen, LastSeen: seen}
+			var existing ProductRecord
+			if found, err := getJSON(productBucket, []byte(key), &existing); err != nil {
+				return err
+			} else if found {
+				record.FirstSeen = existing.FirstSeen
+			}
+			if err := putJSON(productBucket, []byte(key), record); err != nil {
+				return err
+			}
+
+			for _, variant := range product.Variants {
+				if variant.SKU == "" {
+					continue
+				}
+				variantRecord := VariantRecord{
+					SKU:        variant.SKU,
+					ProductKey: key,
+					Size:       variant.Size,
+					Price:      variant.Price,
+					FirstSeen:  seen,
+					LastSeen:   seen,
+				}
+				var existingVariant VariantRecord
+				if found, err := getJSON(variantBucket, []byte(variant.SKU), &existingVariant); err != nil {
+					return err
+				} else if found {
+					variantRecord.FirstSeen = existingVariant.FirstSeen
+				}
+				if err := putJSON(variantBucket, []byte(variant.SKU), variantRecord); err != nil {
+					return err
+				}
+				if err := putPricePoint(tx, variant.SKU, PricePoint{Price: variant.Price, ObservedAt: seen}); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Complete crawls are compared with the previous one for the change log
+		if run.Kind == scrapers.RunCatalogCrawl && run.Error == "" && len(products) > 0 {
+			return recordCrawl(tx, products, seen)
+		}
+
+		return nil
+	})
+}
+
+// RecordStores stores a scrape run and upserts the stores it returned
+func (r *BoltRepository) RecordStores(run scrapers.ScrapeRun, stores []scrapers.StoreResult) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		if err := putRun(tx, run); err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(storesBucket)
+		for _, store := range stores {
+			// Distance only means something relative to the query that found the store
+			store.Distance = 0
+
+			record := StoreRecord{Key: storeKey(store), Store: store, LastSeen: run.FinishedAt}
+			if record.Key == "" {
+				continue
+			}
+			if err := putJSON(bucket, []byte(record.Key), record); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Products returns every stored product ordered by key
+func (r *BoltRepository) Products() ([]ProductRecord, error) {
+	var products []ProductRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			var record ProductRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			products = append(products, record)
+			return nil
+		})
+	})
+	return products, err
+}
+
+// Product returns the stored product with the given key
+func (r *BoltRepository) Product(key string) (*ProductRecord, error) {
+	var record ProductRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return getRequired(tx.Bucket(productsBucket), []byte(key), &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Variant returns the stored variant with the given SKU
+func (r *BoltRepository) Variant(sku string) (*VariantRecord, error) {
+	var record VariantRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return getRequired(tx.Bucket(variantsBucket), []byte(sku), &record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Stores returns every stored store ordered by key
+func (r *BoltRepository) Stores() ([]StoreRecord, error) {
+	var stores []StoreRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(storesBucket).ForEach(func(k, v []byte) error {
+			var record StoreRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			stores = append(stores, record)
+			return nil
+		})
+	})
+	return stores, err
+}
+
+// Runs returns up to limit of the most recent scrape runs, newest first
+func (r *BoltRepository) Runs(limit int) ([]RunRecord, error) {
+	var runs []RunRecord
+	err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(runs) < limit; k, v = c.Prev() {
+			var record RunRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			runs = append(runs, record)
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// PriceHistory returns every recorded price change for a SKU, oldest first
+func (r *BoltRepository) PriceHistory(sku string) ([]PricePoint, error) {
+	var points []PricePoint
+	err := r.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket).Bucket([]byte(sku))
+		if bucket == nil {
+			return ErrNotFound
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var point PricePoint
+			if err := json.Unmarshal(v, &point); err != nil {
+				return err
+			}
+			points = append(points, point)
+			return nil
+		})
+	})
+	return points, err
+}
+
+// putPricePoint appends a price point for a SKU unless the price is unchanged
+// since the last one. Points are keyed by observation time so they stay ordered.
+func putPricePoint(tx *bolt.Tx, sku string, point PricePoint) error {
+	bucket, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(sku))
+	if err != nil {
+		return err
+	}
+
+	if _, last := bucket.Cursor().Last(); last != nil {
+		var previous PricePoint
+		if err := json.Unmarshal(last, &previous); err != nil {
+			return err
+		}
+		if previous.Price == point.Price || !point.ObservedAt.After(previous.ObservedAt) {
+			return nil
+		}
+	}
+
+	return putJSON(bucket, itob(uint64(point.ObservedAt.UnixNano())), point)
+}
+
+// putRun appends a scrape run under the next sequence number and prunes
+// runs beyond the newest MaxRuns
+func putRun(tx *bolt.Tx, run scrapers.ScrapeRun) error {
+	bucket := tx.Bucket(runsBucket)
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	if err := putJSON(bucket, itob(id), RunRecord{ID: id, ScrapeRun: run}); err != nil {
+		return err
+	}
+
+	if MaxRuns <= 0 || id <= uint64(MaxRuns) {
+		return nil
+	}
+	oldest := itob(id - uint64(MaxRuns))
+	c := bucket.Cursor()
+	for k, _ := c.First(); k != nil && bytes.Compare(k, oldest) <= 0; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putJSON stores v as JSON under key
+func putJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+// getJSON decodes the JSON stored under key into v, reporting whether it existed
+func getJSON(bucket *bolt.Bucket, key []byte, v interface{}) (bool, error) {
+	data := bucket.Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// getRequired is getJSON but returns ErrNotFound for a missing key
+func getRequired(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	found, err := getJSON(bucket, key, v)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
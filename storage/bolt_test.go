@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ABCScraper/scrapers"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// openTestRepo opens a fresh database in a temporary directory
+func openTestRepo(t *testing.T) *BoltRepository {
+	t.Helper()
+	repo, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// Helper function to record a successful catalog crawl finished at the given time
+func recordCrawlAt(t *testing.T, repo *BoltRepository, at time.Time, products ...scrapers.ProductResult) {
+	t.Helper()
+	run := scrapers.ScrapeRun{Kind: scrapers.RunCatalogCrawl, StartedAt: at, FinishedAt: at, Count: len(products)}
+	if err := repo.RecordProducts(run, products); err != nil {
+		t.Fatalf("RecordProducts: %v", err)
+	}
+}
+
+func TestProductKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		product scrapers.ProductResult
+		want    string
+	}{
+		{
+			name:    "product ID",
+			product: scrapers.ProductResult{Title: "Tito's Handmade Vodka", ProductID: "010807"},
+			want:    "010807",
+		},
+		{
+			name: "varies uses sorted SKUs",
+			product: scrapers.ProductResult{
+				Title:     "Buffalo Trace 1/2 Gallon",
+				ProductID: "Product # Varies",
+				Variants:  []scrapers.ProductVariant{{SKU: "018006"}, {SKU: "017996"}, {SKU: "018006"}},
+			},
+			want: "017996-018006",
+		},
+		{
+			name:    "falls back to title",
+			product: scrapers.ProductResult{Title: "Mystery Bottle"},
+			want:    "Mystery Bottle",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := productKey(tt.product); got != tt.want {
+				t.Errorf("productKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordProductsKeysByProductID(t *testing.T) {
+	repo := openTestRepo(t)
+	first := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Two products sharing a title are stored separately
+	recordCrawlAt(t, repo, first,
+		scrapers.ProductResult{Title: "Reserve", ProductID: "000111", Variants: []scrapers.ProductVariant{{SKU: "000111", Price: 20}}},
+		scrapers.ProductResult{Title: "Reserve", ProductID: "000222", Variants: []scrapers.ProductVariant{{SKU: "000222", Price: 30}}},
+	)
+	products, err := repo.Products()
+	if err != nil || len(products) != 2 {
+		t.Fatalf("Products = %+v, %v; want two", products, err)
+	}
+	if products[0].Key != "000111" || products[0].Title != "Reserve" {
+		t.Errorf("first product = %+v", products[0])
+	}
+	variant, err := repo.Variant("000222")
+	if err != nil || variant.ProductKey != "000222" {
+		t.Errorf("Variant = %+v, %v; want product key 000222", variant, err)
+	}
+
+	// A rename keeps the key, so it is not logged as a removal and an addition
+	recordCrawlAt(t, repo, first.Add(time.Hour),
+		scrapers.ProductResult{Title: "Reserve Bourbon", ProductID: "000111", Variants: []scrapers.ProductVariant{{SKU: "000111", Price: 20}}},
+		scrapers.ProductResult{Title: "Reserve", ProductID: "000222", Variants: []scrapers.ProductVariant{{SKU: "000222", Price: 30}}},
+	)
+	changes, err := repo.Changes(time.Time{})
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Changes after a rename = %+v, %v; want none", changes, err)
+	}
+	product, err := repo.Product("000111")
+	if err != nil || product.Title != "Reserve Bourbon" {
+		t.Errorf("Product = %+v, %v; want the new title", product, err)
+	}
+}
+
+func TestPutRunKeepsNewestRuns(t *testing.T) {
+	old := MaxRuns
+	MaxRuns = 3
+	defer func() { MaxRuns = old }()
+
+	repo := openTestRepo(t)
+	for i := 0; i < 5; i++ {
+		run := scrapers.ScrapeRun{Kind: scrapers.RunProductSearch, Query: "vodka"}
+		if err := repo.RecordProducts(run, nil); err != nil {
+			t.Fatalf("RecordProducts: %v", err)
+		}
+	}
+
+	runs, err := repo.Runs(10)
+	if err != nil {
+		t.Fatalf("Runs: %v", err)
+	}
+	if len(runs) != 3 || runs[0].ID != 5 || runs[2].ID != 3 {
+		t.Errorf("runs = %+v, want IDs 5, 4 and 3", runs)
+	}
+}
+
+func TestRekeyProductsMigration(t *testing.T) {
+	repo := openTestRepo(t)
+
+	// Records as written when products were keyed by title
+	product := scrapers.ProductResult{Title: "Elijah Craig 1/2", ProductID: "020100", Variants: []scrapers.ProductVariant{{SKU: "020100"}}}
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		if err := putJSON(tx.Bucket(productsBucket), []byte(product.Title), ProductRecord{Key: product.Title, Product: product}); err != nil {
+			return err
+		}
+		if err := putJSON(tx.Bucket(lastCrawlBucket), []byte(product.Title), product); err != nil {
+			return err
+		}
+		return putJSON(tx.Bucket(variantsBucket), []byte("020100"), VariantRecord{SKU: "020100", ProductKey: product.Title})
+	})
+	if err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	if err := repo.db.Update(rekeyProducts); err != nil {
+		t.Fatalf("rekeyProducts: %v", err)
+	}
+
+	record, err := repo.Product("020100")
+	if err != nil || record.Key != "020100" || record.Title != product.Title {
+		t.Errorf("Product = %+v, %v", record, err)
+	}
+	if _, err := repo.Product(product.Title); err != ErrNotFound {
+		t.Errorf("title key still present: %v", err)
+	}
+	if variant, _ := repo.Variant("020100"); variant.ProductKey != "020100" {
+		t.Errorf("variant product key = %q, want 020100", variant.ProductKey)
+	}
+	err = repo.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(lastCrawlBucket).Get([]byte("020100")) == nil {
+			t.Error("crawl baseline was not rekeyed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
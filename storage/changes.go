@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"ABCScraper/scrapers"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Kinds of catalog change detected between successive crawls
+const (
+	ChangeAdded       = "product_added"
+	ChangeRemoved     = "product_removed"
+	ChangePrice       = "price_changed"
+	ChangeSizeAdded   = "size_added"
+	ChangeSizeRemoved = "size_removed"
+	ChangeFlagOn      = "flag_on"
+	ChangeFlagOff     = "flag_off"
+)
+
+// Product flags tracked between crawls
+const (
+	FlagNew       = "new_product"
+	FlagLimited   = "limited_availability"
+	FlagLottery   = "lottery"
+	FlagOnSale    = "on_sale"
+	FlagStore     = "store_inventory"
+	FlagWarehouse = "warehouse_inventory"
+)
+
+// ChangeRetention is how long logged changes are kept; older ones are pruned
+// after each crawl
+var ChangeRetention = 90 * 24 * time.Hour
+
+// Change is one difference between a catalog crawl and the one before it.
+// SKU is set for price and size changes, Field for flag changes, and Old and
+// New hold the before and after values where there are any.
+type Change struct {
+	ID         uint64                  `json:"id"`
+	Kind       string                  `json:"kind"`
+	ProductKey string                  `json:"product_key"`
+	SKU        string                  `json:"sku,omitempty"`
+	Field      string                  `json:"field,omitempty"`
+	Old        interface{}             `json:"old,omitempty"`
+	New        interface{}             `json:"new,omitempty"`
+	DetectedAt time.Time               `json:"detected_at"`
+	Product    *scrapers.ProductResult `json:"product,omitempty"`
+}
+
+// recordCrawl compares a finished crawl with the previous one, appends the
+// differences to the change log and keeps the crawl as the new baseline.
+// Nothing is logged for the first crawl since there is nothing to compare.
+func recordCrawl(tx *bolt.Tx, products []scrapers.ProductResult, at time.Time) error {
+	crawl := tx.Bucket(lastCrawlBucket)
+	baseline := crawl.Stats().KeyN > 0
+
+	current := make(map[string]bool)
+	for _, product := range products {
+		key := productKey(product)
+		if key == "" {
+			continue
+		}
+		current[key] = true
+
+		if !baseline {
+			continue
+		}
+
+		var previous scrapers.ProductResult
+		found, err := getJSON(crawl, []byte(key), &previous)
+		if err != nil {
+			return err
+		}
+
+		var changes []Change
+		if found {
+			changes = diffProducts(key, previous, product)
+		} else {
+			added := product
+			changes = []Change{{Kind: ChangeAdded, ProductKey: key, Product: &added}}
+		}
+
+		for _, change := range changes {
+			change.DetectedAt = at
+			if err := putChange(tx, change); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Products in the previous crawl that this one did not return were removed
+	if baseline {
+		err := crawl.ForEach(func(k, v []byte) error {
+			if current[string(k)] {
+				return nil
+			}
+			var removed scrapers.ProductResult
+			if err := json.Unmarshal(v, &removed); err != nil {
+				return err
+			}
+			return putChange(tx, Change{Kind: ChangeRemoved, ProductKey: string(k), DetectedAt: at, Product: &removed})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := pruneChanges(tx, at.Add(-ChangeRetention)); err != nil {
+		return err
+	}
+
+	// Replace the baseline with this crawl
+	if err := tx.DeleteBucket(lastCrawlBucket); err != nil {
+		return err
+	}
+	crawl, err := tx.CreateBucket(lastCrawlBucket)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		if key := productKey(product); key != "" {
+			if err := putJSON(crawl, []byte(key), product); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// diffProducts returns the price, size and flag changes between two crawls of a product
+func diffProducts(key string, previous, current scrapers.ProductResult) []Change {
+	var changes []Change
+
+	before := make(map[string]scrapers.ProductVariant)
+	for _, variant := range previous.Variants {
+		before[variant.SKU] = variant
+	}
+	after := make(map[string]bool)
+
+	for _, variant := range current.Variants {
+		after[variant.SKU] = true
+		old, found := before[variant.SKU]
+		switch {
+		case !found:
+			changes = append(changes, Change{Kind: ChangeSizeAdded, ProductKey: key, SKU: variant.SKU, New: variant.Size})
+		case old.Price != variant.Price:
+			changes = append(changes, Change{Kind: ChangePrice, ProductKey: key, SKU: variant.SKU, Old: old.Price, New: variant.Price})
+		}
+	}
+	for _, variant := range previous.Variants {
+		if !after[variant.SKU] {
+			changes = append(changes, Change{Kind: ChangeSizeRemoved, ProductKey: key, SKU: variant.SKU, Old: variant.Size})
+		}
+	}
+
+	flags := []struct {
+		name          string
+		before, after bool
+	}{
+		{FlagNew, previous.NewProduct, current.NewProduct},
+		{FlagLimited, previous.LimitedAvailability, current.LimitedAvailability},
+		{FlagLottery, previous.Lottery, current.Lottery},
+		{FlagOnSale, previous.OnSale, current.OnSale},
+		{FlagStore, previous.StoreInventory, current.StoreInventory},
+		{FlagWarehouse, previous.WarehouseInventory, current.WarehouseInventory},
+	}
+	for _, flag := range flags {
+		switch {
+		case flag.after && !flag.before:
+			product := current
+			changes = append(changes, Change{Kind: ChangeFlagOn, ProductKey: key, Field: flag.name, Product: &product})
+		case flag.before && !flag.after:
+			changes = append(changes, Change{Kind: ChangeFlagOff, ProductKey: key, Field: flag.name})
+		}
+	}
+
+	return changes
+}
+
+// putChange appends a change under the next sequence number, keyed by when
+// it was detected so the log can be read from a point in time
+func putChange(tx *bolt.Tx, change Change) error {
+	bucket := tx.Bucket(changesBucket)
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	change.ID = id
+	return putJSON(bucket, changeKey(change), change)
+}
+
+// changeKey orders changes by detection time, then by ID
+func changeKey(change Change) []byte {
+	return append(timeKey(change.DetectedAt), itob(change.ID)...)
+}
+
+// timeKey encodes a time as a sortable key, with times before 1970 as zero
+func timeKey(t time.Time) []byte {
+	if t.Before(time.Unix(0, 0)) {
+		return itob(0)
+	}
+	return itob(uint64(t.UnixNano()))
+}
+
+// pruneChanges deletes changes detected before cutoff
+func pruneChanges(tx *bolt.Tx, cutoff time.Time) error {
+	end := timeKey(cutoff)
+	c := tx.Bucket(changesBucket).Cursor()
+	for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Changes returns every logged change detected after since, oldest first
+func (r *BoltRepository) Changes(since time.Time) ([]Change, error) {
+	var changes []Change
+	err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(changesBucket).Cursor()
+		for k, v := c.Seek(timeKey(since.Add(time.Nanosecond))); k != nil; k, v = c.Next() {
+			var change Change
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	return changes, err
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"ABCScraper/scrapers"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Helper function to build a single-size product at a price
+func pricedProduct(id string, price float64) scrapers.ProductResult {
+	return scrapers.ProductResult{Title: "Product " + id, ProductID: id, Variants: []scrapers.ProductVariant{{SKU: id, Price: price}}}
+}
+
+func TestChangesSince(t *testing.T) {
+	repo := openTestRepo(t)
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	recordCrawlAt(t, repo, start, pricedProduct("000111", 20))
+	recordCrawlAt(t, repo, start.Add(time.Hour), pricedProduct("000111", 18))
+	recordCrawlAt(t, repo, start.Add(2*time.Hour), pricedProduct("000111", 16), pricedProduct("000222", 30))
+
+	all, err := repo.Changes(time.Time{})
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(all) != 3 || all[0].Kind != ChangePrice || all[0].New != 18.0 || all[2].Kind != ChangeAdded {
+		t.Fatalf("all changes = %+v, want the two price drops then the addition", all)
+	}
+
+	// since is exclusive
+	later, err := repo.Changes(start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(later) != 2 || later[0].New != 16.0 || later[0].ID <= all[0].ID {
+		t.Errorf("changes after the second crawl = %+v", later)
+	}
+
+	if none, _ := repo.Changes(start.Add(2 * time.Hour)); len(none) != 0 {
+		t.Errorf("changes after the last crawl = %+v, want none", none)
+	}
+}
+
+func TestChangesArePruned(t *testing.T) {
+	old := ChangeRetention
+	ChangeRetention = 48 * time.Hour
+	defer func() { ChangeRetention = old }()
+
+	repo := openTestRepo(t)
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	recordCrawlAt(t, repo, start, pricedProduct("000111", 20))
+	recordCrawlAt(t, repo, start.Add(24*time.Hour), pricedProduct("000111", 18))
+	recordCrawlAt(t, repo, start.Add(96*time.Hour), pricedProduct("000111", 16))
+
+	changes, err := repo.Changes(time.Time{})
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].New != 16.0 {
+		t.Errorf("changes = %+v, want only the one inside the retention window", changes)
+	}
+}
+
+func TestChangeKeyMigration(t *testing.T) {
+	repo := openTestRepo(t)
+	at := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// Changes as written when they were keyed by sequence number
+	err := repo.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(changesBucket)
+		for id := uint64(1); id <= 2; id++ {
+			change := Change{ID: id, Kind: ChangeAdded, ProductKey: "000111", DetectedAt: at.Add(time.Duration(id) * time.Hour)}
+			if err := putJSON(bucket, itob(id), change); err != nil {
+				return err
+			}
+		}
+		return bucket.SetSequence(2)
+	})
+	if err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	if err := repo.db.Update(migrations[5]); err != nil {
+		t.Fatalf("migration: %v", err)
+	}
+
+	changes, err := repo.Changes(at.Add(time.Hour))
+	if err != nil || len(changes) != 1 || changes[0].ID != 2 {
+		t.Fatalf("Changes = %+v, %v; want the second change", changes, err)
+	}
+
+	// New changes keep numbering after the migrated ones
+	recordCrawlAt(t, repo, at, pricedProduct("000111", 20))
+	recordCrawlAt(t, repo, at.Add(3*time.Hour), pricedProduct("000111", 18))
+	latest, _ := repo.Changes(at.Add(2 * time.Hour))
+	if len(latest) != 1 || latest[0].ID != 3 {
+		t.Errorf("new change = %+v, want ID 3", latest)
+	}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"math"
+	"time"
+)
+
+// PricePoint is a variant price as first observed. A new point is only
+// recorded when the price differs from the previous one.
+type PricePoint struct {
+	Price      float64   `json:"price"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// PriceSummary describes a variant's price history
+type PriceSummary struct {
+	Current      float64   `json:"current"`
+	Min          float64   `json:"min"`
+	Max          float64   `json:"max"`
+	Average      float64   `json:"average"`
+	Observations int       `json:"observations"`
+	Since        time.Time `json:"since"`
+
+	// Windows from LowestPriceWindows, in days, over which the current price is the lowest
+	LowestInDays []int `json:"lowest_in_days"`
+}
+
+// LowestPriceWindows are the day counts checked for PriceSummary.LowestInDays
+var LowestPriceWindows = []int{30, 90, 365}
+
+// SummarizePrices computes min, max, a time-weighted average and the
+// "lowest in N days" flags from a variant's price points, oldest first
+func SummarizePrices(points []PricePoint, now time.Time) PriceSummary {
+	summary := PriceSummary{LowestInDays: []int{}}
+	if len(points) == 0 {
+		return summary
+	}
+
+	current := points[len(points)-1]
+	summary.Current = current.Price
+	summary.Min = points[0].Price
+	summary.Max = points[0].Price
+	summary.Observations = len(points)
+	summary.Since = points[0].ObservedAt
+
+	// Weight each price by how long it was in effect
+	var weighted, total float64
+	for i, point := range points {
+		summary.Min = math.Min(summary.Min, point.Price)
+		summary.Max = math.Max(summary.Max, point.Price)
+
+		end := now
+		if i+1 < len(points) {
+			end = points[i+1].ObservedAt
+		}
+		seconds := end.Sub(point.ObservedAt).Seconds()
+		weighted += point.Price * seconds
+		total += seconds
+	}
+	if total > 0 {
+		summary.Average = math.Round(weighted/total*100) / 100
+	} else {
+		summary.Average = current.Price
+	}
+
+	for _, days := range LowestPriceWindows {
+		windowStart := now.AddDate(0, 0, -days)
+
+		// Without history reaching back to the window start there is no
+		// telling what the price was earlier in it
+		if points[0].ObservedAt.After(windowStart) {
+			continue
+		}
+
+		lowest := true
+		for i, point := range points {
+			// Skip prices that were replaced before the window started
+			if i+1 < len(points) && !points[i+1].ObservedAt.After(windowStart) {
+				continue
+			}
+			if point.Price < current.Price {
+				lowest = false
+				break
+			}
+		}
+		if lowest {
+			summary.LowestInDays = append(summary.LowestInDays, days)
+		}
+	}
+
+	return summary
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarizePricesLowestInDays(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	tests := []struct {
+		name   string
+		points []PricePoint
+		want   []int
+	}{
+		{
+			name:   "first observed an hour ago",
+			points: []PricePoint{{Price: 19.99, ObservedAt: now.Add(-time.Hour)}},
+			want:   []int{},
+		},
+		{
+			name:   "unchanged for 100 days",
+			points: []PricePoint{{Price: 19.99, ObservedAt: daysAgo(100)}},
+			want:   []int{30, 90},
+		},
+		{
+			name: "dropped to a new low",
+			points: []PricePoint{
+				{Price: 24.99, ObservedAt: daysAgo(400)},
+				{Price: 19.99, ObservedAt: daysAgo(10)},
+			},
+			want: []int{30, 90, 365},
+		},
+		{
+			name: "was cheaper 60 days ago",
+			points: []PricePoint{
+				{Price: 17.99, ObservedAt: daysAgo(120)},
+				{Price: 19.99, ObservedAt: daysAgo(60)},
+			},
+			want: []int{30},
+		},
+		{
+			name: "history starts inside the longer windows",
+			points: []PricePoint{
+				{Price: 24.99, ObservedAt: daysAgo(45)},
+				{Price: 19.99, ObservedAt: daysAgo(5)},
+			},
+			want: []int{30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SummarizePrices(tt.points, now).LowestInDays
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LowestInDays = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"ABCScraper/scrapers"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names
+var (
+	metaBucket     = []byte("meta")
+	productsBucket = []byte("products")
+	variantsBucket = []byte("variants")
+	storesBucket   = []byte("stores")
+	runsBucket     = []byte("runs")
+	historyBucket  = []byte("price_history")
+
+	watchesBucket     = []byte("watches")
+	deadLettersBucket = []byte("dead_letters")
+
+	lastCrawlBucket = []byte("last_crawl")
+	changesBucket   = []byte("catalog_changes")
+)
+
+var schemaVersionKey = []byte("schema_version")
+
+// migrations are applied in order inside one transaction each. The schema
+// version stored in the meta bucket is the number of migrations applied, so
+// new migrations must only ever be appended.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: initial catalog buckets
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{productsBucket, variantsBucket, storesBucket, runsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	// 2: price history, one nested bucket of price points per SKU
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(historyBucket)
+		return err
+	},
+
+	// 3: price watchlists and undeliverable webhooks
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{watchesBucket, deadLettersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	// 4: baseline of the last catalog crawl and the log of changes between crawls
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{lastCrawlBucket, changesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	// 5: key products by Coveo product ID instead of title
+	rekeyProducts,
+
+	// 6: key the change log by detection time instead of sequence number
+	func(tx *bolt.Tx) error {
+		changes := make(map[string][]byte)
+		err := tx.Bucket(changesBucket).ForEach(func(k, v []byte) error {
+			var change Change
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			changes[string(changeKey(change))] = v
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sequence := tx.Bucket(changesBucket).Sequence()
+		if err := replaceBucket(tx, changesBucket, changes); err != nil {
+			return err
+		}
+		return tx.Bucket(changesBucket).SetSequence(sequence)
+	},
+}
+
+// rekeyProducts moves products and the last crawl baseline from their title
+// keys to productKey and points each variant at its product's new key
+func rekeyProducts(tx *bolt.Tx) error {
+	renamed := make(map[string]string)
+	products := make(map[string][]byte)
+	err := tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+		var record ProductRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		record.Key = productKey(record.Product)
+		record.Title = record.Product.Title
+		renamed[string(k)] = record.Key
+
+		data, err := json.Marshal(record)
+		products[record.Key] = data
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	baseline := make(map[string][]byte)
+	err = tx.Bucket(lastCrawlBucket).ForEach(func(k, v []byte) error {
+		var product scrapers.ProductResult
+		if err := json.Unmarshal(v, &product); err != nil {
+			return err
+		}
+		baseline[productKey(product)] = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	variants := make(map[string]VariantRecord)
+	err = tx.Bucket(variantsBucket).ForEach(func(k, v []byte) error {
+		var record VariantRecord
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		if key, found := renamed[record.ProductKey]; found && key != record.ProductKey {
+			record.ProductKey = key
+			variants[string(k)] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := replaceBucket(tx, productsBucket, products); err != nil {
+		return err
+	}
+	if err := replaceBucket(tx, lastCrawlBucket, baseline); err != nil {
+		return err
+	}
+	for sku, record := range variants {
+		if err := putJSON(tx.Bucket(variantsBucket), []byte(sku), record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceBucket recreates a bucket holding only the given entries
+func replaceBucket(tx *bolt.Tx, name []byte, entries map[string][]byte) error {
+	if err := tx.DeleteBucket(name); err != nil {
+		return err
+	}
+	bucket, err := tx.CreateBucket(name)
+	if err != nil {
+		return err
+	}
+	for key, value := range entries {
+		if err := bucket.Put([]byte(key), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate brings the database schema up to date
+func migrate(db *bolt.DB) error {
+	var version uint64
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if v := meta.Get(schemaVersionKey); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if version > uint64(len(migrations)) {
+		return fmt.Errorf("database schema version %d is newer than this build (%d)", version, len(migrations))
+	}
+
+	for i := version; i < uint64(len(migrations)); i++ {
+		err := db.Update(func(tx *bolt.Tx) error {
+			if err := migrations[i](tx); err != nil {
+				return err
+			}
+			return tx.Bucket(metaBucket).Put(schemaVersionKey, itob(i+1))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// itob encodes an integer as a big endian key so keys sort numerically
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
@@ -0,0 +1,106 @@
+// Package storage persists the products, variants and stores the scrapers
+// return, along with a log of every scrape run, each variant's price history
+// and the price watchlists.
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"ABCScraper/scrapers"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ProductRecord is a product as last seen by a scrape, keyed by its Coveo
+// product ID, or by its SKUs for products Coveo lists as "# Varies"
+type ProductRecord struct {
+	Key       string                 `json:"key"`
+	Title     string                 `json:"title"`
+	Product   scrapers.ProductResult `json:"product"`
+	FirstSeen time.Time              `json:"first_seen"`
+	LastSeen  time.Time              `json:"last_seen"`
+}
+
+// VariantRecord is one SKU of a product as last seen by a scrape
+type VariantRecord struct {
+	SKU        string    `json:"sku"`
+	ProductKey string    `json:"product_key"`
+	Size       string    `json:"size"`
+	Price      float64   `json:"price"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// StoreRecord is a store as last seen by a scrape, keyed by store number
+type StoreRecord struct {
+	Key      string               `json:"key"`
+	Store    scrapers.StoreResult `json:"store"`
+	LastSeen time.Time            `json:"last_seen"`
+}
+
+// RunRecord is a stored scrape run
+type RunRecord struct {
+	ID uint64 `json:"id"`
+	scrapers.ScrapeRun
+}
+
+// Repository reads and writes the stored catalog. Writes come in through the
+// scrapers.Recorder methods so every scrape is persisted as it happens.
+type Repository interface {
+	scrapers.Recorder
+
+	Products() ([]ProductRecord, error)
+	Product(key string) (*ProductRecord, error)
+	Variant(sku string) (*VariantRecord, error)
+	Stores() ([]StoreRecord, error)
+	Runs(limit int) ([]RunRecord, error)
+	PriceHistory(sku string) ([]PricePoint, error)
+	Changes(since time.Time) ([]Change, error)
+
+	Watches() ([]Watch, error)
+	Watch(id uint64) (*Watch, error)
+	SaveWatch(watch *Watch) error
+	MarkWatchTriggered(id uint64, triggered bool, at time.Time) (*Watch, error)
+	DeleteWatch(id uint64) error
+	AddDeadLetter(letter *DeadLetter) error
+	DeadLetters(limit int) ([]DeadLetter, error)
+	Close() error
+}
+
+// MaxRuns is how many scrape runs are kept; older runs are pruned as new ones are recorded
+var MaxRuns = 10000
+
+// productKey returns the key a product is stored under. Titles are not
+// unique and can change, so the Coveo product ID is used, or the sorted SKUs
+// joined by "-" for products that share the "# Varies" ID. Products with
+// neither fall back to their title.
+func productKey(product scrapers.ProductResult) string {
+	if product.ProductID != "" && !strings.Contains(product.ProductID, "# Varies") {
+		return product.ProductID
+	}
+
+	var skus []string
+	for _, variant := range product.Variants {
+		if variant.SKU != "" {
+			skus = append(skus, variant.SKU)
+		}
+	}
+	if len(skus) == 0 {
+		return product.Title
+	}
+	slices.Sort(skus)
+	return strings.Join(slices.Compact(skus), "-")
+}
+
+// storeKey returns the key a store is stored under, falling back to the
+// title for stores Coveo returned without a store number
+func storeKey(store scrapers.StoreResult) string {
+	if store.StoreNumber != "" {
+		return store.StoreNumber
+	}
+	return store.Title
+}
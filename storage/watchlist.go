@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Watch is a request to be notified through a webhook when a SKU drops
+// below a price or goes on sale
+type Watch struct {
+	ID          uint64    `json:"id"`
+	SKU         string    `json:"sku"`
+	Threshold   float64   `json:"threshold,omitempty"`
+	OnSale      bool      `json:"on_sale"`
+	CallbackURL string    `json:"callback_url"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Set while the watch's condition holds so each drop or sale alerts once
+	Triggered   bool      `json:"triggered"`
+	LastAlertAt time.Time `json:"last_alert_at,omitempty"`
+}
+
+// DeadLetter is a webhook that could not be delivered after every retry
+type DeadLetter struct {
+	ID          uint64          `json:"id"`
+	WatchID     uint64          `json:"watch_id"`
+	CallbackURL string          `json:"callback_url"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	FailedAt    time.Time       `json:"failed_at"`
+}
+
+// Watches returns every watch ordered by ID
+func (r *BoltRepository) Watches() ([]Watch, error) {
+	var watches []Watch
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(watchesBucket).ForEach(func(k, v []byte) error {
+			var watch Watch
+			if err := json.Unmarshal(v, &watch); err != nil {
+				return err
+			}
+			watches = append(watches, watch)
+			return nil
+		})
+	})
+	return watches, err
+}
+
+// Watch returns the watch with the given ID
+func (r *BoltRepository) Watch(id uint64) (*Watch, error) {
+	var watch Watch
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return getRequired(tx.Bucket(watchesBucket), itob(id), &watch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &watch, nil
+}
+
+// SaveWatch creates a watch when its ID is zero, assigning the next ID, and replaces it otherwise
+func (r *BoltRepository) SaveWatch(watch *Watch) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(watchesBucket)
+		if watch.ID == 0 {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			watch.ID = id
+		} else if bucket.Get(itob(watch.ID)) == nil {
+			return ErrNotFound
+		}
+		return putJSON(bucket, itob(watch.ID), watch)
+	})
+}
+
+// MarkWatchTriggered sets whether a watch's condition holds, stamping
+// LastAlertAt with at when it starts to. It re-reads the watch in the same
+// transaction so edits made since it was loaded are kept, and returns the
+// watch as stored.
+func (r *BoltRepository) MarkWatchTriggered(id uint64, triggered bool, at time.Time) (*Watch, error) {
+	var watch Watch
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(watchesBucket)
+		if err := getRequired(bucket, itob(id), &watch); err != nil {
+			return err
+		}
+		watch.Triggered = triggered
+		if triggered {
+			watch.LastAlertAt = at
+		}
+		return putJSON(bucket, itob(id), &watch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &watch, nil
+}
+
+// DeleteWatch removes the watch with the given ID
+func (r *BoltRepository) DeleteWatch(id uint64) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(watchesBucket)
+		if bucket.Get(itob(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete(itob(id))
+	})
+}
+
+// AddDeadLetter records an undeliverable webhook, assigning it the next ID
+func (r *BoltRepository) AddDeadLetter(letter *DeadLetter) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadLettersBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		letter.ID = id
+		return putJSON(bucket, itob(id), letter)
+	})
+}
+
+// DeadLetters returns up to limit of the most recent undeliverable webhooks, newest first
+func (r *BoltRepository) DeadLetters(limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	err := r.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(deadLettersBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(letters) < limit; k, v = c.Prev() {
+			var letter DeadLetter
+			if err := json.Unmarshal(v, &letter); err != nil {
+				return err
+			}
+			letters = append(letters, letter)
+		}
+		return nil
+	})
+	return letters, err
+}